@@ -0,0 +1,263 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/authorizer"
+	"github.com/SSHcom/privx-sdk-go/api/hoststore"
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/spf13/cobra"
+)
+
+type accessOptions struct {
+	host   string
+	userID string
+	format string
+}
+
+// accessEntry is a row of effective access matrix
+type accessEntry struct {
+	UserID      string `json:"user_id"`
+	User        string `json:"user"`
+	HostID      string `json:"host_id"`
+	Host        string `json:"host"`
+	Principal   string `json:"principal"`
+	RoleID      string `json:"role_id"`
+	Role        string `json:"role"`
+	AccessGroup string `json:"access_group"`
+}
+
+func init() {
+	rootCmd.AddCommand(accessCmd())
+}
+
+//
+//
+func accessCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "access",
+		Short: "Report effective access to hosts",
+		Long: `Report effective access to hosts as user, host, principal, role and access group
+matrix. The matrix joins role memberships with host principal to role mappings.`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(accessWhoCmd())
+	cmd.AddCommand(accessWhatCmd())
+
+	return cmd
+}
+
+//
+//
+func accessWhoCmd() *cobra.Command {
+	options := accessOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "who",
+		Short: "List users who can access the host",
+		Long:  `List users who can access the host and the principals they can use`,
+		Example: `
+	privx-cli access who [access flags] --host <HOST-ID>
+	privx-cli access who [access flags] --host <COMMON-NAME> --format csv > access.csv
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return accessWho(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.host, "host", "", "host ID or common name")
+	flags.StringVar(&options.format, "format", "json", "output format, table, csv, html or json")
+	cmd.MarkFlagRequired("host")
+
+	return cmd
+}
+
+//
+//
+func accessWhatCmd() *cobra.Command {
+	options := accessOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "what",
+		Short: "List hosts the user can access",
+		Long:  `List hosts the user can access and the principals the user can use`,
+		Example: `
+	privx-cli access what [access flags] --user <USER-ID>
+	privx-cli access what [access flags] --user <USER-ID> --format html > access.html
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return accessWhat(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.userID, "user", "", "user ID")
+	flags.StringVar(&options.format, "format", "json", "output format, table, csv, html or json")
+	cmd.MarkFlagRequired("user")
+
+	return cmd
+}
+
+func accessWho(options accessOptions) error {
+	host, err := findHost(options.host)
+	if err != nil {
+		return err
+	}
+
+	api := rolestore.New(curl())
+	groups := accessGroupNames()
+	members := map[string][]rolestore.User{}
+	entries := []accessEntry{}
+
+	for _, principal := range host.Principals {
+		for _, role := range principal.Roles {
+			if _, ok := members[role.ID]; !ok {
+				users, err := api.GetRoleMembers(role.ID)
+				if err != nil {
+					return err
+				}
+				members[role.ID] = users
+			}
+
+			for _, user := range members[role.ID] {
+				entries = append(entries, accessEntry{
+					UserID:      user.ID,
+					User:        user.Principal,
+					HostID:      host.ID,
+					Host:        host.Name,
+					Principal:   principal.ID,
+					RoleID:      role.ID,
+					Role:        role.Name,
+					AccessGroup: groups(host.AccessGroupID),
+				})
+			}
+		}
+	}
+
+	return accessReport(options.format, entries)
+}
+
+func accessWhat(options accessOptions) error {
+	api := rolestore.New(curl())
+
+	user, err := api.User(options.userID)
+	if err != nil {
+		return err
+	}
+
+	roles, err := api.UserRoles(options.userID)
+	if err != nil {
+		return err
+	}
+
+	granted := map[string]string{}
+	for _, role := range roles {
+		granted[role.ID] = role.Name
+	}
+
+	hosts, err := allHosts(hoststore.New(curl()))
+	if err != nil {
+		return err
+	}
+
+	groups := accessGroupNames()
+	entries := []accessEntry{}
+
+	for _, host := range hosts {
+		for _, principal := range host.Principals {
+			for _, role := range principal.Roles {
+				name, ok := granted[role.ID]
+				if !ok {
+					continue
+				}
+
+				entries = append(entries, accessEntry{
+					UserID:      user.ID,
+					User:        user.Principal,
+					HostID:      host.ID,
+					Host:        host.Name,
+					Principal:   principal.ID,
+					RoleID:      role.ID,
+					Role:        firstOf(role.Name, name),
+					AccessGroup: groups(host.AccessGroupID),
+				})
+			}
+		}
+	}
+
+	return accessReport(options.format, entries)
+}
+
+func accessReport(format string, entries []accessEntry) error {
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		return strings.Join([]string{a.Host, a.User, a.Principal, a.Role}, "\x00") <
+			strings.Join([]string{b.Host, b.User, b.Principal, b.Role}, "\x00")
+	})
+
+	return report(format, entries, func() *table {
+		t := newTable("USER", "HOST", "PRINCIPAL", "ROLE", "ACCESS GROUP", "USER ID", "HOST ID", "ROLE ID")
+		for _, e := range entries {
+			t.add(e.User, e.Host, e.Principal, e.Role, e.AccessGroup, e.UserID, e.HostID, e.RoleID)
+		}
+		return t
+	})
+}
+
+// findHost gets host by ID, or by common name if no host has the ID
+func findHost(host string) (*hoststore.Host, error) {
+	api := hoststore.New(curl())
+
+	if found, err := api.Host(host); err == nil && found != nil && found.ID != "" {
+		return found, nil
+	}
+
+	hosts, err := allHosts(api)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range hosts {
+		if strings.EqualFold(hosts[i].Name, host) {
+			return &hosts[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("host does not exist: %s", host)
+}
+
+// accessGroupNames returns lookup of access group names, falling back to
+// access group ID if the name cannot be resolved
+func accessGroupNames() func(id string) string {
+	api := authorizer.New(curl())
+	names := map[string]string{}
+
+	return func(id string) string {
+		if id == "" {
+			return ""
+		}
+
+		if _, ok := names[id]; !ok {
+			names[id] = id
+
+			group, err := api.AccessGroup(id)
+			if err == nil && group != nil && group.Name != "" {
+				names[id] = group.Name
+			}
+		}
+
+		return names[id]
+	}
+}
@@ -0,0 +1,177 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+type apiOptions struct {
+	data     string
+	query    []string
+	paginate bool
+	limit    int
+}
+
+// apiPage is the common envelope of PrivX list responses
+type apiPage struct {
+	Count int               `json:"count"`
+	Items []json.RawMessage `json:"items"`
+}
+
+func init() {
+	rootCmd.AddCommand(apiCmd())
+}
+
+//
+//
+func apiCmd() *cobra.Command {
+	options := apiOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "api",
+		Short: "Call PrivX REST API directly",
+		Long: `Call PrivX REST API endpoints that are not wrapped by other commands.
+The request uses the same credentials and TLS trust anchor as the other commands.
+Request body is given either inline, from a file (@FILE) or from stdin (-).`,
+		Example: `
+	privx-cli api [access flags] GET /host-store/api/v1/hosts --query limit=10
+	privx-cli api [access flags] GET /host-store/api/v1/hosts --paginate
+	privx-cli api [access flags] POST /role-store/api/v1/roles --data @role.json
+	cat role.json | privx-cli api [access flags] PUT /role-store/api/v1/roles/<ROLE-ID> --data -
+	privx-cli api [access flags] DELETE /role-store/api/v1/roles/<ROLE-ID>
+		`,
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return apiRequest(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.data, "data", "", "request body, inline JSON, @FILE or - for stdin")
+	flags.StringArrayVar(&options.query, "query", []string{}, "query parameter as key=value")
+	flags.BoolVar(&options.paginate, "paginate", false, "fetch all pages of GET request")
+	flags.IntVar(&options.limit, "limit", 100, "number of items to fetch per page with --paginate")
+
+	return cmd
+}
+
+func apiRequest(options apiOptions, args []string) error {
+	method := strings.ToUpper(args[0])
+	path := "/" + strings.TrimPrefix(args[1], "/")
+
+	query, err := apiQuery(options.query)
+	if err != nil {
+		return err
+	}
+
+	if options.paginate {
+		if method != http.MethodGet {
+			return errors.New("--paginate is supported only with GET requests")
+		}
+		return apiPaginate(path, query, options.limit)
+	}
+
+	body, err := apiData(options.data)
+	if err != nil {
+		return err
+	}
+
+	var result json.RawMessage
+	request := curl().URL(path).Query(query)
+
+	switch method {
+	case http.MethodGet:
+		_, err = request.Get(&result)
+	case http.MethodPost:
+		_, err = request.Post(body, &result)
+	case http.MethodPut:
+		_, err = request.Put(body, &result)
+	case http.MethodDelete:
+		_, err = request.Delete()
+	default:
+		return fmt.Errorf("method is not supported: %s", method)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+
+	return stdout(result)
+}
+
+func apiPaginate(path string, query map[string]string, limit int) error {
+	if limit <= 0 {
+		return errors.New("limit must be positive")
+	}
+
+	result := apiPage{Items: []json.RawMessage{}}
+
+	for offset := 0; ; offset += limit {
+		var page apiPage
+
+		query["offset"] = strconv.Itoa(offset)
+		query["limit"] = strconv.Itoa(limit)
+		_, err := curl().URL(path).Query(query).Get(&page)
+		if err != nil {
+			return err
+		}
+
+		result.Count = page.Count
+		result.Items = append(result.Items, page.Items...)
+
+		if len(page.Items) < limit || (page.Count > 0 && len(result.Items) >= page.Count) {
+			break
+		}
+	}
+
+	return stdout(result)
+}
+
+func apiQuery(params []string) (map[string]string, error) {
+	return keyValues(params, "query parameter")
+}
+
+func apiData(data string) (json.RawMessage, error) {
+	var raw []byte
+	var err error
+
+	switch {
+	case data == "":
+		return json.RawMessage("{}"), nil
+	case data == "-":
+		raw, err = io.ReadAll(os.Stdin)
+	case strings.HasPrefix(data, "@"):
+		raw, err = os.ReadFile(strings.TrimPrefix(data, "@"))
+	default:
+		raw = []byte(data)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !json.Valid(raw) {
+		return nil, errors.New("request body is not valid JSON")
+	}
+
+	return json.RawMessage(raw), nil
+}
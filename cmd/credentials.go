@@ -0,0 +1,280 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/csv"
+	"fmt"
+	"math/big"
+	"os/exec"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/userstore"
+	"github.com/SSHcom/privx-sdk-go/api/vault"
+	"github.com/spf13/pflag"
+)
+
+// passwordPolicy defines generated passwords, every character class given
+// is present in the password at least once
+type passwordPolicy struct {
+	length  int
+	symbols string
+}
+
+// credentialOptions tells where generated credentials are stored, they are
+// never printed in plain text
+type credentialOptions struct {
+	out           string
+	ageRecipients []string
+	pgpRecipients []string
+	vaultPrefix   string
+	vaultReadTo   []string
+	vaultWriteTo  []string
+	secretName    string
+}
+
+// credential is a generated password of local user
+type credential struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+const (
+	passwordLower  = "abcdefghijkmnopqrstuvwxyz"
+	passwordUpper  = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	passwordDigits = "23456789"
+)
+
+func (policy *passwordPolicy) flags(flags *pflag.FlagSet) {
+	flags.IntVar(&policy.length, "password-length", 20, "length of generated passwords")
+	flags.StringVar(&policy.symbols, "password-symbols", "!#%+-.:=?@_~", "symbols used in generated passwords, empty for none")
+}
+
+func (options *credentialOptions) flags(flags *pflag.FlagSet) {
+	flags.StringVar(&options.out, "out", "", "write credentials to encrypted file")
+	flags.StringSliceVar(&options.ageRecipients, "age-recipient", []string{}, "age recipient(s) of the credentials file")
+	flags.StringSliceVar(&options.pgpRecipients, "pgp-recipient", []string{}, "PGP recipient(s) of the credentials file")
+	flags.StringVar(&options.vaultPrefix, "vault-prefix", "", "store credentials as vault secrets named with the prefix and username")
+	flags.StringArrayVar(&options.vaultReadTo, "allow-read-to", []string{}, "role ID allowed to read the credential secrets")
+	flags.StringArrayVar(&options.vaultWriteTo, "allow-write-to", []string{}, "role ID allowed to write the credential secrets")
+}
+
+// validate checks that credentials have a safe destination before any
+// password is generated
+func (options credentialOptions) validate() error {
+	encrypted := len(options.ageRecipients) > 0 || len(options.pgpRecipients) > 0
+
+	vault := options.vaultPrefix != "" || options.secretName != ""
+
+	switch {
+	case options.out == "" && !vault:
+		return fmt.Errorf("credentials need destination, use --out with --age-recipient or --pgp-recipient, or vault secrets")
+	case options.out != "" && vault, options.vaultPrefix != "" && options.secretName != "":
+		return fmt.Errorf("credentials can have only one destination")
+	case options.out != "" && !encrypted:
+		return fmt.Errorf("credentials file must be encrypted, use --age-recipient or --pgp-recipient")
+	case len(options.ageRecipients) > 0 && len(options.pgpRecipients) > 0:
+		return fmt.Errorf("use either --age-recipient or --pgp-recipient")
+	case vault && (len(options.vaultReadTo) == 0 || len(options.vaultWriteTo) == 0):
+		return fmt.Errorf("credential secrets need --allow-read-to and --allow-write-to roles")
+	}
+
+	if options.out != "" {
+		if _, err := exec.LookPath(options.encryptor()[0]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (options credentialOptions) encryptor() []string {
+	if len(options.ageRecipients) > 0 {
+		command := []string{"age"}
+		for _, recipient := range options.ageRecipients {
+			command = append(command, "--recipient", recipient)
+		}
+		return command
+	}
+
+	command := []string{"gpg", "--batch", "--yes", "--armor", "--trust-model", "always", "--encrypt"}
+	for _, recipient := range options.pgpRecipients {
+		command = append(command, "--recipient", recipient)
+	}
+	return command
+}
+
+// writeCredentials writes credentials as CSV to the encrypted file
+func (options credentialOptions) writeCredentials(credentials []credential) error {
+	var plain bytes.Buffer
+	w := csv.NewWriter(&plain)
+	w.Write([]string{"user_id", "username", "password"})
+	for _, c := range credentials {
+		w.Write([]string{c.UserID, c.Username, c.Password})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	var encrypted, stderr bytes.Buffer
+	command := options.encryptor()
+	encrypt := exec.Command(command[0], command[1:]...)
+	encrypt.Stdin = &plain
+	encrypt.Stdout = &encrypted
+	encrypt.Stderr = &stderr
+
+	if err := encrypt.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", command[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return writeSecretFile(options.out, encrypted.Bytes())
+}
+
+// apply stores the credentials and sets them as passwords of local users.
+// Credentials are stored first, so that no password is set without a stored
+// copy, and earlier vault secret is restored if the password cannot be set.
+// Returns error of each credential.
+func (options credentialOptions) apply(credentials []credential, workers int) []error {
+	failures := make([]error, len(credentials))
+	if len(credentials) == 0 {
+		return failures
+	}
+
+	if options.out != "" {
+		if err := options.writeCredentials(credentials); err != nil {
+			for i := range failures {
+				failures[i] = fmt.Errorf("password not set, writing credentials failed: %w", err)
+			}
+			return failures
+		}
+	}
+
+	api := userstore.New(curl())
+	parallel(len(credentials), workers, func(i int) {
+		c := credentials[i]
+
+		restore := func() error { return nil }
+		if options.out == "" {
+			var err error
+			if restore, err = options.storeCredential(c); err != nil {
+				failures[i] = fmt.Errorf("password not set, storing credential failed: %w", err)
+				return
+			}
+		}
+
+		err := api.UpdateLocalUserPassword(c.UserID, &userstore.Password{Password: c.Password})
+		if err == nil {
+			return
+		}
+
+		if rerr := restore(); rerr != nil {
+			failures[i] = fmt.Errorf("password not set: %w, restoring previous credential failed: %v", err, rerr)
+		} else {
+			failures[i] = fmt.Errorf("password not set, previous credential restored: %w", err)
+		}
+	})
+
+	return failures
+}
+
+// storeCredential stores the credential as vault secret, replacing earlier
+// secret of the same name. Returns function restoring the earlier secret, or
+// deleting the new one, for the case the password cannot be changed.
+func (options credentialOptions) storeCredential(c credential) (func() error, error) {
+	api := vault.New(curl())
+	data := map[string]string{"username": c.Username, "password": c.Password}
+
+	name := options.secretName
+	if name == "" {
+		name = options.vaultPrefix + c.Username
+	}
+
+	previous, err := api.Secret(name)
+	if err != nil || previous == nil {
+		if err := api.CreateSecret(name, options.vaultReadTo, options.vaultWriteTo, data); err != nil {
+			return nil, err
+		}
+		return func() error { return api.DeleteSecret(name) }, nil
+	}
+
+	if err := api.UpdateSecret(name, options.vaultReadTo, options.vaultWriteTo, data); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		readTo, writeTo := []string{}, []string{}
+		for _, ref := range previous.AllowRead {
+			readTo = append(readTo, ref.ID)
+		}
+		for _, ref := range previous.AllowWrite {
+			writeTo = append(writeTo, ref.ID)
+		}
+		return api.UpdateSecret(name, readTo, writeTo, previous.Data)
+	}, nil
+}
+
+// minimum length of generated passwords
+const passwordMinLength = 12
+
+func (policy passwordPolicy) validate() error {
+	if policy.length < passwordMinLength {
+		return fmt.Errorf("password length must be at least %d", passwordMinLength)
+	}
+	return nil
+}
+
+// generate returns random password following the policy
+func (policy passwordPolicy) generate() (string, error) {
+	if err := policy.validate(); err != nil {
+		return "", err
+	}
+
+	classes := []string{passwordLower, passwordUpper, passwordDigits}
+	if policy.symbols != "" {
+		classes = append(classes, policy.symbols)
+	}
+
+	pick := func(chars string) (byte, error) {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return 0, err
+		}
+		return chars[n.Int64()], nil
+	}
+
+	password := make([]byte, 0, policy.length)
+	for _, class := range classes {
+		c, err := pick(class)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+
+	all := strings.Join(classes, "")
+	for len(password) < policy.length {
+		c, err := pick(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+
+	return string(password), nil
+}
@@ -0,0 +1,57 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyGenerate(t *testing.T) {
+	for _, policy := range []passwordPolicy{
+		{length: 12, symbols: "!#%"},
+		{length: 20, symbols: ""},
+		{length: 64, symbols: "@"},
+	} {
+		classes := []string{passwordLower, passwordUpper, passwordDigits}
+		if policy.symbols != "" {
+			classes = append(classes, policy.symbols)
+		}
+		all := strings.Join(classes, "")
+
+		seen := map[string]bool{}
+		for i := 0; i < 50; i++ {
+			password, err := policy.generate()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(password) != policy.length {
+				t.Errorf("password %q length %d, expected %d", password, len(password), policy.length)
+			}
+			for _, class := range classes {
+				if !strings.ContainsAny(password, class) {
+					t.Errorf("password %q has no character of %q", password, class)
+				}
+			}
+			if strings.Trim(password, all) != "" {
+				t.Errorf("password %q has characters outside the policy", password)
+			}
+
+			if seen[password] {
+				t.Errorf("password %q generated twice", password)
+			}
+			seen[password] = true
+		}
+	}
+}
+
+func TestPasswordPolicyTooShort(t *testing.T) {
+	if password, err := (passwordPolicy{length: passwordMinLength - 1}).generate(); err == nil {
+		t.Errorf("generated %q, expected error", password)
+	}
+}
@@ -0,0 +1,198 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type exporterOptions struct {
+	listen   string
+	path     string
+	interval time.Duration
+}
+
+// metrics renders samples in Prometheus text exposition format. Samples of
+// the same metric are expected to be added one after another.
+type metrics struct {
+	buffer bytes.Buffer
+	last   string
+}
+
+func init() {
+	rootCmd.AddCommand(exporterCmd())
+}
+
+//
+//
+func exporterCmd() *cobra.Command {
+	options := exporterOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "exporter",
+		Short: "Expose PrivX health and usage as Prometheus metrics",
+		Long: `Expose PrivX health and usage as Prometheus metrics. The exporter polls component,
+instance, license, connection, network access manager, UEBA and workflow status
+periodically and serves the latest figures over HTTP.`,
+		Example: `
+	privx-cli exporter [access flags] --listen :9810
+	privx-cli exporter [access flags] --listen 127.0.0.1:9810 --interval 5m
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return exporter(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.listen, "listen", ":9810", "address to serve metrics at")
+	flags.StringVar(&options.path, "path", "/metrics", "HTTP path of metrics")
+	flags.DurationVar(&options.interval, "interval", time.Minute, "how often PrivX is polled")
+
+	return cmd
+}
+
+func exporter(options exporterOptions) error {
+	if options.interval <= 0 {
+		return fmt.Errorf("interval must be positive: %s", options.interval)
+	}
+
+	var lock sync.RWMutex
+	snapshot := collectMetrics()
+
+	go func() {
+		for range time.Tick(options.interval) {
+			latest := collectMetrics()
+
+			lock.Lock()
+			snapshot = latest
+			lock.Unlock()
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(options.path, func(w http.ResponseWriter, r *http.Request) {
+		lock.RLock()
+		defer lock.RUnlock()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write(snapshot)
+	})
+
+	fmt.Fprintf(os.Stderr, "serving metrics at %s%s\n", options.listen, options.path)
+	return http.ListenAndServe(options.listen, mux)
+}
+
+func collectMetrics() []byte {
+	m := &metrics{}
+	failed := map[string]bool{}
+	failure := func(collector string, err error) bool {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", collector, err)
+			failed[collector] = true
+		}
+		return err != nil
+	}
+
+	if components, err := componentsStatus(); !failure("components", err) {
+		for _, component := range components {
+			m.gauge("privx_component_up", "Whether PrivX component is up (1) or down (0).",
+				boolValue(component.up()), "component", component.Name, "host", component.Hostname)
+		}
+	}
+
+	if instance, err := instanceStatus(); !failure("instance", err) {
+		m.gauge("privx_instance_up", "Whether PrivX instance is up (1) or down (0).",
+			boolValue(instance.up()))
+	}
+
+	if license, err := licenseUsage(); !failure("license", err) {
+		m.gauge("privx_license_seats", "Number of licensed seats.", float64(license.MaxUsers), "kind", "users")
+		m.gauge("privx_license_seats", "", float64(license.MaxHosts), "kind", "hosts")
+		m.gauge("privx_license_seats_used", "Number of used seats.", float64(license.Users), "kind", "users")
+		m.gauge("privx_license_seats_used", "", float64(license.Hosts), "kind", "hosts")
+
+		if left, ok := licenseExpiresIn(license); ok {
+			m.gauge("privx_license_expiry_days", "Days until PrivX license expires.", left.Hours()/24)
+		}
+	}
+
+	if protocols, err := activeConnections(); !failure("connections", err) {
+		for _, protocol := range sortedKeys(protocols) {
+			m.gauge("privx_connections_active", "Number of active connections per protocol.",
+				float64(protocols[protocol]), "protocol", protocol)
+		}
+	}
+
+	if nam, err := namStatus(); !failure("nam", err) {
+		m.gauge("privx_nam_up", "Whether network access manager is up (1) or down (0).", boolValue(nam.up()))
+	}
+
+	if ueba, err := uebaServiceStatus(); !failure("ueba", err) {
+		m.gauge("privx_ueba_up", "Whether UEBA is up (1) or down (0).", boolValue(ueba.up()))
+	}
+
+	if pending, err := pendingApprovals(); !failure("workflow", err) {
+		m.gauge("privx_workflow_pending_approvals", "Number of workflow requests waiting for approval.", float64(pending))
+	}
+
+	for _, collector := range []string{"components", "instance", "license", "connections", "nam", "ueba", "workflow"} {
+		m.gauge("privx_exporter_collector_success", "Whether the last poll of the collector succeeded.",
+			boolValue(!failed[collector]), "collector", collector)
+	}
+
+	return m.buffer.Bytes()
+}
+
+// gauge adds a sample, labels are given as name, value pairs
+func (m *metrics) gauge(name, help string, value float64, labels ...string) {
+	if m.last != name {
+		if help != "" {
+			fmt.Fprintf(&m.buffer, "# HELP %s %s\n", name, help)
+		}
+		fmt.Fprintf(&m.buffer, "# TYPE %s gauge\n", name)
+		m.last = name
+	}
+
+	pairs := []string{}
+	for i := 0; i+1 < len(labels); i += 2 {
+		label := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[i+1])
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], label))
+	}
+
+	if len(pairs) > 0 {
+		fmt.Fprintf(&m.buffer, "%s{%s} %g\n", name, strings.Join(pairs, ","), value)
+	} else {
+		fmt.Fprintf(&m.buffer, "%s %g\n", name, value)
+	}
+}
+
+func boolValue(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+func sortedKeys(bag map[string]int) []string {
+	keys := make([]string, 0, len(bag))
+	for key := range bag {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
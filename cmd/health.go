@@ -0,0 +1,281 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/authorizer"
+	"github.com/spf13/cobra"
+)
+
+type healthOptions struct {
+	licenseWarning  int
+	licenseCritical int
+	seatsWarning    int
+	seatsCritical   int
+	certWarning     int
+	certCritical    int
+	json            bool
+}
+
+// healthState follows monitoring plugin exit codes
+type healthState int
+
+const (
+	healthOK healthState = iota
+	healthWarning
+	healthCritical
+	healthUnknown
+)
+
+type healthCheck struct {
+	Name    string `json:"name"`
+	State   string `json:"state"`
+	Message string `json:"message"`
+	state   healthState
+}
+
+type healthReport struct {
+	State  string        `json:"state"`
+	Checks []healthCheck `json:"checks"`
+}
+
+func init() {
+	rootCmd.AddCommand(healthCmd())
+}
+
+//
+//
+func healthCmd() *cobra.Command {
+	options := healthOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "health",
+		Short: "Check PrivX health",
+		Long: `Check PrivX health against thresholds. Evaluates components, instance, license
+expiry and seats, CA certificate expiry, network access manager and UEBA status.
+Prints one line summary and exits with monitoring plugin codes:
+0 (OK), 1 (WARNING), 2 (CRITICAL) or 3 (UNKNOWN). Invalid flags and output
+errors are UNKNOWN as well.`,
+		Example: `
+	privx-cli health [access flags]
+	privx-cli health [access flags] --license-warning 60 --cert-critical 14
+	privx-cli health [access flags] --json
+		`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return healthError(health(options))
+		},
+	}
+
+	cmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return healthError(err)
+	})
+
+	flags := cmd.Flags()
+	flags.IntVar(&options.licenseWarning, "license-warning", 30, "warn when license expires within given days")
+	flags.IntVar(&options.licenseCritical, "license-critical", 7, "critical when license expires within given days")
+	flags.IntVar(&options.seatsWarning, "seats-warning", 90, "warn when given percentage of licensed seats is used")
+	flags.IntVar(&options.seatsCritical, "seats-critical", 100, "critical when given percentage of licensed seats is used")
+	flags.IntVar(&options.certWarning, "cert-warning", 30, "warn when CA certificate expires within given days")
+	flags.IntVar(&options.certCritical, "cert-critical", 7, "critical when CA certificate expires within given days")
+	flags.BoolVar(&options.json, "json", false, "print each individual check as JSON")
+
+	return cmd
+}
+
+func health(options healthOptions) error {
+	checks := []healthCheck{}
+	checks = append(checks, componentsHealth()...)
+	checks = append(checks, serviceHealth("instance", instanceStatus))
+	checks = append(checks, licenseHealth(options)...)
+	checks = append(checks, certificateHealth(options)...)
+	checks = append(checks, serviceHealth("nam", namStatus))
+	checks = append(checks, serviceHealth("ueba", uebaServiceStatus))
+
+	state := healthOK
+	for _, check := range checks {
+		if check.state.worse(state) {
+			state = check.state
+		}
+	}
+
+	if options.json {
+		if err := stdout(healthReport{State: state.String(), Checks: checks}); err != nil {
+			return err
+		}
+	} else {
+		fmt.Println(healthSummary(state, checks))
+	}
+
+	if state != healthOK {
+		return &exitError{code: int(state), message: "PRIVX " + state.String()}
+	}
+
+	return nil
+}
+
+// healthError reports UNKNOWN state when the check itself fails, instead
+// of the generic exit code of failing commands
+func healthError(err error) error {
+	if _, ok := err.(*exitError); err == nil || ok {
+		return err
+	}
+
+	fmt.Printf("PRIVX UNKNOWN - %v\n", err)
+	return &exitError{code: int(healthUnknown), message: "PRIVX UNKNOWN - " + err.Error()}
+}
+
+func healthSummary(state healthState, checks []healthCheck) string {
+	problems := []string{}
+	for _, check := range checks {
+		if check.state != healthOK {
+			problems = append(problems, fmt.Sprintf("%s: %s", check.Name, check.Message))
+		}
+	}
+
+	if len(problems) == 0 {
+		return fmt.Sprintf("PRIVX OK - %d checks passed", len(checks))
+	}
+
+	return fmt.Sprintf("PRIVX %s - %s", state, strings.Join(problems, "; "))
+}
+
+func componentsHealth() []healthCheck {
+	components, err := componentsStatus()
+	if err != nil {
+		return []healthCheck{newHealthCheck("components", healthUnknown, err.Error())}
+	}
+
+	checks := []healthCheck{}
+	for _, component := range components {
+		name := "component " + component.name()
+		if component.up() {
+			checks = append(checks, newHealthCheck(name, healthOK, "up"))
+		} else {
+			checks = append(checks, newHealthCheck(name, healthCritical, "down, status "+component.Status))
+		}
+	}
+
+	return checks
+}
+
+func serviceHealth(name string, status func() (serviceStatus, error)) healthCheck {
+	service, err := status()
+	if err != nil {
+		return newHealthCheck(name, healthUnknown, err.Error())
+	}
+
+	if !service.up() {
+		return newHealthCheck(name, healthCritical, "down, status "+service.Status)
+	}
+
+	return newHealthCheck(name, healthOK, "up")
+}
+
+func licenseHealth(options healthOptions) []healthCheck {
+	license, err := licenseUsage()
+	if err != nil {
+		return []healthCheck{newHealthCheck("license", healthUnknown, err.Error())}
+	}
+
+	checks := []healthCheck{}
+
+	if left, ok := licenseExpiresIn(license); ok {
+		days := int(left.Hours() / 24)
+		message := fmt.Sprintf("expires in %d days", days)
+		checks = append(checks, newHealthCheck("license expiry",
+			thresholdState(-days, -options.licenseWarning, -options.licenseCritical), message))
+	} else {
+		checks = append(checks, newHealthCheck("license expiry", healthUnknown, "unknown expiry "+license.Expiry))
+	}
+
+	seats := map[string][2]int{
+		"users": {license.Users, license.MaxUsers},
+		"hosts": {license.Hosts, license.MaxHosts},
+	}
+	for _, kind := range []string{"users", "hosts"} {
+		used, limit := seats[kind][0], seats[kind][1]
+		if limit <= 0 {
+			continue
+		}
+
+		percent := used * 100 / limit
+		message := fmt.Sprintf("%d of %d seats used (%d%%)", used, limit, percent)
+		checks = append(checks, newHealthCheck("license "+kind,
+			thresholdState(percent, options.seatsWarning, options.seatsCritical), message))
+	}
+
+	return checks
+}
+
+func certificateHealth(options healthOptions) []healthCheck {
+	certs, err := authorizer.New(curl()).GetAllCertificates()
+	if err != nil {
+		return []healthCheck{newHealthCheck("certificates", healthUnknown, err.Error())}
+	}
+
+	checks := []healthCheck{}
+	for _, cert := range certs {
+		name := "certificate " + cert.Subject
+		if cert.Subject == "" {
+			name = "certificate " + cert.ID
+		}
+
+		notAfter, err := time.Parse(time.RFC3339, cert.NotAfter)
+		if err != nil {
+			checks = append(checks, newHealthCheck(name, healthUnknown, "unknown expiry "+cert.NotAfter))
+			continue
+		}
+
+		days := int(time.Until(notAfter).Hours() / 24)
+		message := fmt.Sprintf("expires in %d days", days)
+		checks = append(checks, newHealthCheck(name,
+			thresholdState(-days, -options.certWarning, -options.certCritical), message))
+	}
+
+	return checks
+}
+
+func newHealthCheck(name string, state healthState, message string) healthCheck {
+	return healthCheck{Name: name, State: state.String(), Message: message, state: state}
+}
+
+// thresholdState evaluates value growing towards warning and critical limits
+func thresholdState(value, warning, critical int) healthState {
+	switch {
+	case value >= critical:
+		return healthCritical
+	case value >= warning:
+		return healthWarning
+	default:
+		return healthOK
+	}
+}
+
+// worse orders states by severity: OK, WARNING, UNKNOWN, CRITICAL
+func (state healthState) worse(than healthState) bool {
+	severity := map[healthState]int{healthOK: 0, healthWarning: 1, healthUnknown: 2, healthCritical: 3}
+	return severity[state] > severity[than]
+}
+
+func (state healthState) String() string {
+	switch state {
+	case healthOK:
+		return "OK"
+	case healthWarning:
+		return "WARNING"
+	case healthCritical:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
@@ -0,0 +1,151 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/connectionmanager"
+	"github.com/SSHcom/privx-sdk-go/api/hoststore"
+	"github.com/spf13/cobra"
+)
+
+type hostAuditOptions struct {
+	format    string
+	staleDays int
+}
+
+type hostAuditFinding struct {
+	ID             string   `json:"id"`
+	CommonName     string   `json:"common_name"`
+	Addresses      []string `json:"addresses"`
+	LastConnection string   `json:"last_connection,omitempty"`
+	Findings       []string `json:"findings"`
+}
+
+//
+//
+func hostAuditCmd() *cobra.Command {
+	options := hostAuditOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Report hosts which need cleanup",
+		Long: `Report hosts which are disabled, not deployable, have no principals or access
+group, share addresses with other hosts, have never been connected or whose last
+connection is older than --stale-days. Connection history is read from
+connection manager.`,
+		Example: `
+	privx-cli hosts audit [access flags]
+	privx-cli hosts audit [access flags] --stale-days 180 --format csv > audit.csv
+	privx-cli hosts audit [access flags] --format json
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return hostAudit(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.format, "format", "table", "output format, table, csv or json")
+	flags.IntVar(&options.staleDays, "stale-days", 90, "flag hosts whose last connection is older than given days")
+
+	return cmd
+}
+
+func hostAudit(options hostAuditOptions) error {
+	switch options.format {
+	case "table", "csv", "json":
+	default:
+		return fmt.Errorf("output format does not exist: %s", options.format)
+	}
+
+	hosts, err := allHosts(hoststore.New(curl()))
+	if err != nil {
+		return err
+	}
+
+	lastConnected, err := lastConnections(func(conn connectionmanager.Connection) string { return conn.TargetHost.ID })
+	if err != nil {
+		return err
+	}
+
+	hostsByAddress := map[string][]string{}
+	for _, host := range hosts {
+		for _, address := range uniqueStrings(hostAddresses(host)) {
+			hostsByAddress[address] = append(hostsByAddress[address], host.Name)
+		}
+	}
+
+	stale := time.Now().AddDate(0, 0, -options.staleDays)
+	findings := []hostAuditFinding{}
+
+	for _, host := range hosts {
+		finding := hostAuditFinding{
+			ID:         host.ID,
+			CommonName: host.Name,
+			Addresses:  hostAddresses(host),
+			Findings:   []string{},
+		}
+
+		if hostDisabled(host) {
+			finding.Findings = append(finding.Findings, "disabled")
+		}
+		if !host.Deployable {
+			finding.Findings = append(finding.Findings, "not deployable")
+		}
+		if len(host.Principals) == 0 {
+			finding.Findings = append(finding.Findings, "no principals")
+		}
+		if host.AccessGroupID == "" {
+			finding.Findings = append(finding.Findings, "no access group")
+		}
+
+		for _, address := range uniqueStrings(finding.Addresses) {
+			if others := hostsByAddress[address]; len(others) > 1 {
+				finding.Findings = append(finding.Findings,
+					fmt.Sprintf("duplicate address %s (%s)", address, strings.Join(others, ", ")))
+			}
+		}
+
+		if last, ok := lastConnected[host.ID]; !ok {
+			finding.Findings = append(finding.Findings, "never connected")
+		} else {
+			finding.LastConnection = last.Format(time.RFC3339)
+			if last.Before(stale) {
+				days := int(time.Since(last).Hours() / 24)
+				finding.Findings = append(finding.Findings, fmt.Sprintf("last connection %d days ago", days))
+			}
+		}
+
+		if len(finding.Findings) > 0 {
+			findings = append(findings, finding)
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool { return findings[i].CommonName < findings[j].CommonName })
+
+	return report(options.format, findings, func() *table {
+		t := newTable("ID", "COMMON NAME", "ADDRESSES", "LAST CONNECTION", "FINDINGS")
+		for _, finding := range findings {
+			t.add(finding.ID, finding.CommonName, strings.Join(finding.Addresses, " "),
+				finding.LastConnection, strings.Join(finding.Findings, "; "))
+		}
+		return t
+	})
+}
+
+func hostAddresses(host hoststore.Host) []string {
+	addresses := []string{}
+	for _, address := range host.Addresses {
+		addresses = append(addresses, string(address))
+	}
+	return addresses
+}
@@ -0,0 +1,147 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/SSHcom/privx-sdk-go/api/authorizer"
+	"github.com/SSHcom/privx-sdk-go/api/userstore"
+	"gopkg.in/yaml.v3"
+)
+
+// locations of deployment files on target hosts
+const (
+	deployConfigPath  = "/etc/privx/deploy.conf"
+	deployAnchorPath  = "/etc/privx/privx-ca.pem"
+	deployPermissions = "0600"
+)
+
+var hostProvisioningClient = string(userstore.HostProvisioning("").Type)
+
+// output formats of deployment bundle
+var deployFormats = []string{"raw", "cloud-init", "shell", "ansible"}
+
+var deployShellTemplate = template.Must(template.New("deploy").Parse(`#!/bin/sh
+# PrivX host deployment {{.Name}}, generated by privx-cli hosts deploy
+set -eu
+umask 077
+mkdir -p /etc/privx
+
+base64 -d > {{.ConfigPath}} <<'PRIVX_DEPLOY_CONFIG'
+{{.Config}}
+PRIVX_DEPLOY_CONFIG
+
+cat > {{.AnchorPath}} <<'PRIVX_TRUST_ANCHOR'
+{{.TrustAnchor}}
+PRIVX_TRUST_ANCHOR
+
+# PRIVX_DEPLOY_COMMAND is run once the files are in place, if given
+if [ -n "${PRIVX_DEPLOY_COMMAND:-}" ]; then
+	sh -c "$PRIVX_DEPLOY_COMMAND"
+fi
+`))
+
+type deployFile struct {
+	Path        string `yaml:"path"`
+	Permissions string `yaml:"permissions"`
+	Encoding    string `yaml:"encoding,omitempty"`
+	Content     string `yaml:"content"`
+}
+
+func validDeployFormat(format string) bool {
+	for _, known := range deployFormats {
+		if format == known {
+			return true
+		}
+	}
+	return false
+}
+
+// deployBundle wraps deployment config for the given provisioning tool
+func deployBundle(format, name string, config []byte) ([]byte, error) {
+	if format == "raw" {
+		return config, nil
+	}
+
+	anchor, err := trustAnchor()
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(config)
+
+	switch format {
+	case "cloud-init":
+		data, err := yaml.Marshal(map[string]interface{}{
+			"write_files": []deployFile{
+				{Path: deployConfigPath, Permissions: deployPermissions, Encoding: "b64", Content: encoded},
+				{Path: deployAnchorPath, Permissions: deployPermissions, Content: anchor},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte("#cloud-config\n"), data...), nil
+
+	case "shell":
+		var script bytes.Buffer
+		err := deployShellTemplate.Execute(&script, map[string]string{
+			"Name":        name,
+			"ConfigPath":  deployConfigPath,
+			"AnchorPath":  deployAnchorPath,
+			"Config":      wrapLines(encoded, 76),
+			"TrustAnchor": anchor,
+		})
+		return script.Bytes(), err
+
+	case "ansible":
+		data, err := yaml.Marshal(map[string]string{
+			"privx_deploy_name":        name,
+			"privx_deploy_config_path": deployConfigPath,
+			"privx_deploy_config_b64":  encoded,
+			"privx_trust_anchor_path":  deployAnchorPath,
+			"privx_trust_anchor":       anchor,
+			"privx_deploy_file_mode":   deployPermissions,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte("---\n"), data...), nil
+
+	default:
+		return nil, fmt.Errorf("deployment format does not exist: %s", format)
+	}
+}
+
+func trustAnchor() (string, error) {
+	anchor, err := authorizer.New(curl()).SSLTrustAnchor()
+	if err != nil {
+		return "", err
+	}
+
+	if strings.TrimSpace(anchor.TrustAnchor) == "" {
+		return "", errors.New("PrivX returned empty trust anchor")
+	}
+
+	return anchor.TrustAnchor, nil
+}
+
+func wrapLines(text string, width int) string {
+	var wrapped bytes.Buffer
+	for len(text) > width {
+		wrapped.WriteString(text[:width] + "\n")
+		text = text[width:]
+	}
+	wrapped.WriteString(text)
+	return wrapped.String()
+}
@@ -0,0 +1,262 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/hoststore"
+	"github.com/spf13/cobra"
+)
+
+type hostExportOptions struct {
+	tags      []string
+	proxyJump string
+	user      string
+	out       string
+}
+
+var sshAliasUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+//
+//
+func hostExportSSHConfigCmd() *cobra.Command {
+	options := hostExportOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "export-ssh-config",
+		Short: "Export hosts as OpenSSH client configuration",
+		Long: `Export hosts as OpenSSH client configuration. Each enabled host with SSH service
+accessible to the user gets a Host block named by its common name, with address,
+port and the first principal as user. Optionally connections jump through PrivX
+SSH bastion.`,
+		Example: `
+	privx-cli hosts export-ssh-config [access flags] > ~/.ssh/privx_config
+	privx-cli hosts export-ssh-config [access flags] --tag prod --proxy-jump bastion.example.com:2222
+	privx-cli hosts export-ssh-config [access flags] --user alice --out ~/.ssh/privx_config
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return hostExportSSHConfig(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringSliceVar(&options.tags, "tag", []string{}, "export only hosts with given tags")
+	flags.StringVar(&options.proxyJump, "proxy-jump", "", "PrivX SSH bastion as [USER@]HOST[:PORT]")
+	flags.StringVar(&options.user, "user", "", "user name instead of host principal")
+	flags.StringVar(&options.out, "out", "", "output file (default stdout)")
+
+	return cmd
+}
+
+//
+//
+func hostExportKnownHostsCmd() *cobra.Command {
+	options := hostExportOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "export-known-hosts",
+		Short: "Export host public keys as OpenSSH known_hosts",
+		Long: `Export SSH public keys recorded for hosts as OpenSSH known_hosts file.
+Hosts without recorded keys are skipped.`,
+		Example: `
+	privx-cli hosts export-known-hosts [access flags] >> ~/.ssh/known_hosts
+	privx-cli hosts export-known-hosts [access flags] --tag prod --out ~/.ssh/privx_known_hosts
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return hostExportKnownHosts(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringSliceVar(&options.tags, "tag", []string{}, "export only hosts with given tags")
+	flags.StringVar(&options.out, "out", "", "output file (default stdout)")
+
+	return cmd
+}
+
+func hostExportSSHConfig(options hostExportOptions) error {
+	hosts, err := exportedHosts(options.tags)
+	if err != nil {
+		return err
+	}
+
+	var config bytes.Buffer
+	fmt.Fprintf(&config, "# Generated by privx-cli hosts export-ssh-config\n")
+
+	// names already written, so that numbered duplicates do not clash with
+	// host names of the same form
+	emitted := map[string]bool{}
+	for _, host := range hosts {
+		for _, service := range host.Services {
+			if !strings.EqualFold(string(service.Scheme), "SSH") {
+				continue
+			}
+
+			base := sshAlias(host)
+			alias := base
+			for n := 2; emitted[strings.ToLower(alias)]; n++ {
+				alias = fmt.Sprintf("%s-%d", base, n)
+			}
+			emitted[strings.ToLower(alias)] = true
+
+			fmt.Fprintf(&config, "\nHost %s\n", alias)
+			fmt.Fprintf(&config, "    HostName %s\n", serviceAddress(host, service))
+			if service.Port != 0 && service.Port != 22 {
+				fmt.Fprintf(&config, "    Port %d\n", service.Port)
+			}
+			if user := firstOf(options.user, hostPrincipal(host)); user != "" {
+				fmt.Fprintf(&config, "    User %s\n", user)
+			}
+			if options.proxyJump != "" {
+				fmt.Fprintf(&config, "    ProxyJump %s\n", options.proxyJump)
+			}
+		}
+	}
+
+	return writeExport(options.out, config.Bytes())
+}
+
+func hostExportKnownHosts(options hostExportOptions) error {
+	hosts, err := exportedHosts(options.tags)
+	if err != nil {
+		return err
+	}
+
+	var known bytes.Buffer
+	for _, host := range hosts {
+		if len(host.PublicKeys) == 0 {
+			continue
+		}
+
+		patterns := knownHostPatterns(host)
+		if len(patterns) == 0 {
+			continue
+		}
+
+		for _, key := range host.PublicKeys {
+			if key.Key == "" {
+				continue
+			}
+			fmt.Fprintf(&known, "%s %s\n", strings.Join(patterns, ","), strings.TrimSpace(key.Key))
+		}
+	}
+
+	return writeExport(options.out, known.Bytes())
+}
+
+// exportedHosts returns enabled hosts accessible by the caller having all
+// given tags
+func exportedHosts(tags []string) ([]hoststore.Host, error) {
+	hosts, err := searchHosts(hoststore.New(curl()), "accessible", &hoststore.HostSearchObject{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := []hoststore.Host{}
+	for _, host := range hosts {
+		if !hostDisabled(host) && hasTags(host, tags) {
+			result = append(result, host)
+		}
+	}
+
+	return result, nil
+}
+
+func hasTags(host hoststore.Host, tags []string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, hostTag := range host.Tags {
+			if hostTag == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func sshAlias(host hoststore.Host) string {
+	alias := strings.Trim(sshAliasUnsafe.ReplaceAllString(host.Name, "-"), "-")
+	if alias == "" {
+		alias = host.ID
+	}
+	return alias
+}
+
+func serviceAddress(host hoststore.Host, service hoststore.Service) string {
+	if service.Address != "" {
+		return string(service.Address)
+	}
+	if len(host.Addresses) > 0 {
+		return string(host.Addresses[0])
+	}
+	return host.Name
+}
+
+func hostPrincipal(host hoststore.Host) string {
+	if len(host.Principals) > 0 {
+		return host.Principals[0].ID
+	}
+	return ""
+}
+
+// knownHostPatterns lists host names and addresses in known_hosts notation,
+// non-standard SSH ports are given as [address]:port
+func knownHostPatterns(host hoststore.Host) []string {
+	names := []string{}
+	if host.Name != "" {
+		names = append(names, host.Name)
+	}
+	for _, address := range host.Addresses {
+		names = append(names, string(address))
+	}
+
+	ports := []int{}
+	for _, service := range host.Services {
+		if strings.EqualFold(string(service.Scheme), "SSH") && service.Port != 0 {
+			ports = append(ports, service.Port)
+		}
+	}
+	if len(ports) == 0 {
+		ports = append(ports, 22)
+	}
+
+	seen := map[string]bool{}
+	patterns := []string{}
+	for _, port := range ports {
+		for _, name := range names {
+			pattern := name
+			if port != 22 {
+				pattern = fmt.Sprintf("[%s]:%d", name, port)
+			}
+			if !seen[pattern] {
+				seen[pattern] = true
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+
+	return patterns
+}
+
+func writeExport(out string, data []byte) error {
+	if out == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(out, data, 0644)
+}
@@ -0,0 +1,620 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/hoststore"
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+type hostImportOptions struct {
+	format    string
+	separator string
+	mapping   []string
+	workers   int
+	dryRun    bool
+}
+
+// hostRecord is a row of the import file, attributes are either CSV columns
+// or Ansible host variables
+type hostRecord struct {
+	row   int
+	attrs map[string]string
+}
+
+type hostImportResult struct {
+	Row        int    `json:"row"`
+	CommonName string `json:"common_name"`
+	Action     string `json:"action"`
+	ID         string `json:"id,omitempty"`
+	Error      string `json:"error,omitempty"`
+	host       *hoststore.Host
+}
+
+type ansibleGroup struct {
+	Hosts    map[string]map[string]interface{} `yaml:"hosts"`
+	Vars     map[string]interface{}            `yaml:"vars"`
+	Children map[string]*ansibleGroup          `yaml:"children"`
+}
+
+// host fields which can be imported, in the order they are applied
+var hostImportFields = []string{
+	"common_name",
+	"external_id",
+	"access_group_id",
+	"addresses",
+	"services",
+	"roles",
+	"principals",
+	"tags",
+}
+
+var defaultServicePorts = map[string]int{
+	"SSH": 22,
+	"RDP": 3389,
+	"VNC": 5900,
+	"WEB": 443,
+}
+
+//
+//
+func hostImportCmd() *cobra.Command {
+	options := hostImportOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Create or update hosts from CSV file or Ansible inventory",
+		Long: `Create or update hosts from CSV file or Ansible INI/YAML inventory.
+Existing hosts are matched by external ID, then by common name unless the host
+has another external ID. Imported fields are
+common_name, external_id, access_group_id, addresses, services, principals, roles
+and tags. Fields are read from CSV columns or Ansible variables of the same name,
+--map FIELD=COLUMN reads a field from another column or variable. List values
+are separated by --separator, services are given as SCHEME[:PORT] (e.g. ssh:22),
+roles (role IDs) are granted to each of the imported principals.
+
+Ansible inventories provide defaults: common_name is the inventory host name,
+addresses is ansible_host, services is ssh at ansible_port, principals is
+ansible_user and tags are the names of the host groups. Variables of all group
+apply to every host, variables of child groups override parent groups, groups of
+the same depth are applied by name and host variables override group variables.`,
+		Example: `
+	privx-cli hosts import [access flags] --dry-run hosts.csv
+	privx-cli hosts import [access flags] --map addresses=ip --map tags=env hosts.csv
+	privx-cli hosts import [access flags] --format ini --workers 8 inventory
+	privx-cli hosts import [access flags] --map access_group_id=privx_access_group inventory.yml
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return hostImport(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.format, "format", "", "file format csv, ini or yaml (default by file extension)")
+	flags.StringVar(&options.separator, "separator", ";", "separator of list values")
+	flags.StringArrayVar(&options.mapping, "map", []string{}, "read host field from column or variable, FIELD=COLUMN")
+	flags.IntVar(&options.workers, "workers", 4, "number of concurrent requests")
+	flags.BoolVar(&options.dryRun, "dry-run", false, "show the plan without changing hosts")
+
+	return cmd
+}
+
+func hostImport(options hostImportOptions, args []string) error {
+	records, err := readHostRecords(args[0], options.format, options.separator)
+	if err != nil {
+		return err
+	}
+
+	mapping, err := hostImportMapping(options.mapping)
+	if err != nil {
+		return err
+	}
+
+	api := hoststore.New(curl())
+	hosts, err := allHosts(api)
+	if err != nil {
+		return err
+	}
+
+	results := planHostImport(records, hosts, mapping, options.separator)
+	if options.dryRun {
+		return stdout(results)
+	}
+
+	parallel(len(results), options.workers, func(i int) {
+		result := &results[i]
+
+		switch result.Action {
+		case "create":
+			id, err := api.CreateHost(*result.host)
+			if err != nil {
+				result.Error = err.Error()
+				return
+			}
+			result.ID = id
+		case "update":
+			if err := api.UpdateHost(result.ID, result.host); err != nil {
+				result.Error = err.Error()
+			}
+		}
+	})
+
+	return stdout(results)
+}
+
+func hostImportMapping(mapping []string) (map[string]string, error) {
+	fields := map[string]string{}
+	for _, field := range hostImportFields {
+		fields[field] = field
+	}
+
+	for _, pair := range mapping {
+		field, column, ok := strings.Cut(pair, "=")
+		if _, known := fields[field]; !ok || !known {
+			return nil, fmt.Errorf("invalid mapping %s, expected FIELD=COLUMN with field one of %q",
+				pair, hostImportFields)
+		}
+		fields[field] = column
+	}
+
+	return fields, nil
+}
+
+// planHostImport decides action for each record, hosts are matched by
+// external ID and then by common name
+func planHostImport(records []hostRecord, hosts []hoststore.Host, mapping map[string]string, separator string) []hostImportResult {
+	byKey := map[string]hoststore.Host{}
+	for _, host := range hosts {
+		if host.ExternalID != "" {
+			byKey["external_id:"+host.ExternalID] = host
+		}
+		byKey["common_name:"+strings.ToLower(host.Name)] = host
+	}
+
+	// common name matches only hosts without a conflicting external ID
+	match := func(fields map[string]string) (hoststore.Host, bool) {
+		if host, ok := byKey["external_id:"+fields["external_id"]]; ok && fields["external_id"] != "" {
+			return host, true
+		}
+
+		host, ok := byKey["common_name:"+strings.ToLower(fields["common_name"])]
+		if !ok || fields["common_name"] == "" {
+			return hoststore.Host{}, false
+		}
+		if host.ExternalID != "" && fields["external_id"] != "" && host.ExternalID != fields["external_id"] {
+			return hoststore.Host{}, false
+		}
+
+		return host, true
+	}
+
+	results := []hostImportResult{}
+	seen := map[string]int{}
+
+	for _, record := range records {
+		fields := map[string]string{}
+		for field, column := range mapping {
+			value, ok := record.attrs[column]
+			if !ok {
+				value = record.attrs[strings.ToLower(column)]
+			}
+			fields[field] = strings.TrimSpace(value)
+		}
+
+		result := hostImportResult{Row: record.row, CommonName: fields["common_name"]}
+
+		existing, found := match(fields)
+		key := "id:" + existing.ID
+		switch {
+		case !found && fields["external_id"] != "":
+			key = "external_id:" + fields["external_id"]
+		case !found:
+			key = "common_name:" + strings.ToLower(fields["common_name"])
+		}
+
+		switch {
+		case fields["common_name"] == "" && fields["external_id"] == "":
+			result.Action, result.Error = "error", "common name or external ID is required"
+		case seen[key] != 0:
+			result.Action, result.Error = "error", fmt.Sprintf("duplicate of row %d", seen[key])
+		default:
+			seen[key] = record.row
+			result.Action, result.ID, result.host = planHost(existing, found, fields, separator, &result.Error)
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+func planHost(existing hoststore.Host, found bool, fields map[string]string, separator string, failure *string) (string, string, *hoststore.Host) {
+	host := existing
+
+	if err := applyHostFields(&host, fields, separator); err != nil {
+		*failure = err.Error()
+		return "error", existing.ID, nil
+	}
+
+	if !found {
+		return "create", "", &host
+	}
+
+	before, _ := json.Marshal(existing)
+	after, _ := json.Marshal(host)
+	if string(before) == string(after) {
+		return "unchanged", existing.ID, nil
+	}
+
+	return "update", existing.ID, &host
+}
+
+// applyHostFields sets non-empty fields of the record to the host
+func applyHostFields(host *hoststore.Host, fields map[string]string, separator string) error {
+	roles := []rolestore.RoleRef{}
+
+	for _, field := range hostImportFields {
+		value := fields[field]
+		if value == "" {
+			continue
+		}
+
+		switch field {
+		case "common_name":
+			host.Name = value
+		case "external_id":
+			host.ExternalID = value
+		case "access_group_id":
+			host.AccessGroupID = value
+		case "addresses":
+			host.Addresses = []hoststore.Address{}
+			for _, address := range splitList(value, separator) {
+				host.Addresses = append(host.Addresses, hoststore.Address(address))
+			}
+		case "services":
+			services, err := parseServices(value, separator, host.Addresses)
+			if err != nil {
+				return err
+			}
+			host.Services = services
+		case "roles":
+			for _, id := range splitList(value, separator) {
+				roles = append(roles, rolestore.RoleRef{ID: id})
+			}
+		case "principals":
+			host.Principals = []hoststore.Principal{}
+			for _, name := range splitList(value, separator) {
+				host.Principals = append(host.Principals, hoststore.Principal{ID: name, Roles: roles})
+			}
+		case "tags":
+			host.Tags = splitList(value, separator)
+		}
+	}
+
+	return nil
+}
+
+func parseServices(value, separator string, addresses []hoststore.Address) ([]hoststore.Service, error) {
+	services := []hoststore.Service{}
+
+	for _, item := range splitList(value, separator) {
+		scheme, port, hasPort := strings.Cut(item, ":")
+		scheme = strings.ToUpper(scheme)
+
+		service := hoststore.Service{
+			Scheme: hoststore.Scheme(scheme),
+			Port:   defaultServicePorts[scheme],
+		}
+
+		if hasPort {
+			number, err := strconv.Atoi(port)
+			if err != nil {
+				return nil, fmt.Errorf("invalid service port %s", item)
+			}
+			service.Port = number
+		}
+
+		if service.Port == 0 {
+			return nil, fmt.Errorf("port is required for service %s", item)
+		}
+
+		if len(addresses) > 0 {
+			service.Address = addresses[0]
+		}
+
+		services = append(services, service)
+	}
+
+	return services, nil
+}
+
+func splitList(value, separator string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, separator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func readHostRecords(name, format, separator string) ([]hostRecord, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".csv":
+			format = "csv"
+		case ".yml", ".yaml":
+			format = "yaml"
+		default:
+			format = "ini"
+		}
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	switch strings.ToLower(format) {
+	case "csv":
+		return readCSVRecords(file)
+	case "ini":
+		groups, err := readINIInventory(file)
+		if err != nil {
+			return nil, err
+		}
+		return ansibleRecords(groups, separator), nil
+	case "yaml", "yml":
+		groups := map[string]*ansibleGroup{}
+		if err := yaml.NewDecoder(file).Decode(&groups); err != nil {
+			return nil, err
+		}
+		return ansibleRecords(groups, separator), nil
+	default:
+		return nil, fmt.Errorf("file format does not exist: %s", format)
+	}
+}
+
+// readCSVRecords reads rows of CSV file, columns are in lower case
+func readCSVRecords(file io.Reader) ([]hostRecord, error) {
+	input, err := readCSVTable(file)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []hostRecord{}
+	for _, record := range input.records {
+		records = append(records, hostRecord{row: record.row, attrs: record.cells})
+	}
+
+	return records, nil
+}
+
+// readINIInventory parses Ansible INI inventory into groups, host ranges
+// (e.g. web[01:10]) are not expanded
+func readINIInventory(file io.Reader) (map[string]*ansibleGroup, error) {
+	groups := map[string]*ansibleGroup{}
+	group := func(name string) *ansibleGroup {
+		if groups[name] == nil {
+			groups[name] = &ansibleGroup{
+				Hosts:    map[string]map[string]interface{}{},
+				Vars:     map[string]interface{}{},
+				Children: map[string]*ansibleGroup{},
+			}
+		}
+		return groups[name]
+	}
+
+	isChild := map[string]bool{}
+	section, kind := "ungrouped", "hosts"
+	scanner := bufio.NewScanner(file)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section, kind, _ = strings.Cut(line[1:len(line)-1], ":")
+			if kind == "" {
+				kind = "hosts"
+			}
+			group(section)
+			continue
+		}
+
+		fields, err := splitCommandLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+
+		switch kind {
+		case "hosts":
+			vars := map[string]interface{}{}
+			for _, field := range fields[1:] {
+				key, value, _ := strings.Cut(field, "=")
+				vars[key] = value
+			}
+			group(section).Hosts[fields[0]] = vars
+		case "vars":
+			key, value, _ := strings.Cut(line, "=")
+			group(section).Vars[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+		case "children":
+			group(section).Children[fields[0]] = group(fields[0])
+			isChild[fields[0]] = true
+		default:
+			return nil, fmt.Errorf("line %d: unknown section type %s", n, kind)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	roots := map[string]*ansibleGroup{}
+	for name, g := range groups {
+		if !isChild[name] {
+			roots[name] = g
+		}
+	}
+
+	return roots, nil
+}
+
+// ansibleRecords flattens groups into host records. Variables are applied
+// in the order of Ansible: all group first, then groups from parents to
+// children and by name within the same depth, host variables last
+func ansibleRecords(groups map[string]*ansibleGroup, separator string) []hostRecord {
+	depths := map[string]int{}
+	occurrences := map[string][]*ansibleGroup{}
+	memberOf := map[string]map[string]bool{}
+
+	var walk func(name string, group *ansibleGroup, path []string)
+	walk = func(name string, group *ansibleGroup, path []string) {
+		if group == nil {
+			return
+		}
+		for _, parent := range path {
+			if parent == name {
+				return
+			}
+		}
+
+		path = append(path, name)
+		if depth, ok := depths[name]; !ok || len(path) > depth {
+			depths[name] = len(path)
+		}
+		occurrences[name] = append(occurrences[name], group)
+
+		for host := range group.Hosts {
+			if memberOf[host] == nil {
+				memberOf[host] = map[string]bool{}
+			}
+			for _, member := range path {
+				memberOf[host][member] = true
+			}
+		}
+
+		for _, child := range groupNames(group.Children) {
+			walk(child, group.Children[child], append([]string{}, path...))
+		}
+	}
+
+	for _, name := range groupNames(groups) {
+		walk(name, groups[name], []string{})
+	}
+	depths["all"] = 0
+
+	names := make([]string, 0, len(memberOf))
+	for host := range memberOf {
+		names = append(names, host)
+	}
+	sort.Strings(names)
+
+	records := []hostRecord{}
+	for i, host := range names {
+		member := []string{}
+		for name := range memberOf[host] {
+			member = append(member, name)
+		}
+		if occurrences["all"] != nil && !memberOf[host]["all"] {
+			member = append(member, "all")
+		}
+		sort.Slice(member, func(i, j int) bool {
+			if depths[member[i]] != depths[member[j]] {
+				return depths[member[i]] < depths[member[j]]
+			}
+			return member[i] < member[j]
+		})
+
+		attrs := map[string]string{}
+		tags := []string{}
+		for _, name := range member {
+			for _, group := range occurrences[name] {
+				for key, value := range group.Vars {
+					attrs[key] = fmt.Sprint(value)
+				}
+			}
+			if name != "all" && name != "ungrouped" {
+				tags = append(tags, name)
+			}
+		}
+		for _, name := range member {
+			for _, group := range occurrences[name] {
+				for key, value := range group.Hosts[host] {
+					attrs[key] = fmt.Sprint(value)
+				}
+			}
+		}
+
+		ansibleDefault(attrs, "inventory_hostname", host)
+		ansibleDefault(attrs, "common_name", host)
+		ansibleDefault(attrs, "addresses", firstOf(attrs["ansible_host"], host))
+		ansibleDefault(attrs, "services", "ssh:"+firstOf(attrs["ansible_port"], "22"))
+		ansibleDefault(attrs, "principals", attrs["ansible_user"])
+		ansibleDefault(attrs, "tags", strings.Join(uniqueStrings(tags), separator))
+
+		records = append(records, hostRecord{row: i + 1, attrs: attrs})
+	}
+
+	return records
+}
+
+// groupNames returns names of groups in order, so that groups are walked
+// the same way on every run
+func groupNames(groups map[string]*ansibleGroup) []string {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func ansibleDefault(attrs map[string]string, key, value string) {
+	if _, ok := attrs[key]; !ok {
+		attrs[key] = value
+	}
+}
+
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
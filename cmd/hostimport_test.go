@@ -0,0 +1,218 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SSHcom/privx-sdk-go/api/hoststore"
+)
+
+const testInventory = `
+# web servers
+web1.example.com ansible_host=10.0.0.1 ansible_user=deploy
+web2.example.com ansible_port=2222 tags="a;b"
+bastion.example.com
+
+[db]
+db1.example.com
+app1.example.com
+
+[db:vars]
+ansible_user = "postgres"
+
+[prod:children]
+web
+db
+
+[web]
+web1.example.com
+web2.example.com
+app1.example.com
+
+[web:vars]
+ansible_user=www
+
+[all:vars]
+ansible_user=admin
+`
+
+func TestReadINIInventory(t *testing.T) {
+	groups, err := readINIInventory(strings.NewReader(testInventory))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(groups) != 3 || groups["all"] == nil || groups["prod"] == nil || groups["ungrouped"] == nil {
+		t.Fatalf("unexpected root groups %v", groups)
+	}
+
+	prod := groups["prod"]
+	if prod.Children["db"] == nil || prod.Children["web"] == nil {
+		t.Fatalf("prod children %v", prod.Children)
+	}
+	if user := prod.Children["db"].Vars["ansible_user"]; user != "postgres" {
+		t.Errorf("db vars ansible_user %q", user)
+	}
+	if user := groups["all"].Vars["ansible_user"]; user != "admin" {
+		t.Errorf("all vars ansible_user %q", user)
+	}
+	if vars := groups["ungrouped"].Hosts["web2.example.com"]; vars["tags"] != "a;b" || vars["ansible_port"] != "2222" {
+		t.Errorf("host vars %v", vars)
+	}
+}
+
+func TestReadINIInventoryErrors(t *testing.T) {
+	for _, inventory := range []string{
+		"[web:unknown]\nweb1",
+		"web1 ansible_host=\"10.0.0.1",
+	} {
+		if _, err := readINIInventory(strings.NewReader(inventory)); err == nil {
+			t.Errorf("readINIInventory(%q), expected error", inventory)
+		}
+	}
+}
+
+func TestAnsibleRecords(t *testing.T) {
+	groups, err := readINIInventory(strings.NewReader(testInventory))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := ansibleRecords(groups, ",")
+	attrs := map[string]map[string]string{}
+	for _, record := range records {
+		attrs[record.attrs["inventory_hostname"]] = record.attrs
+	}
+
+	for _, test := range []struct {
+		host, key, expected string
+	}{
+		{"web1.example.com", "addresses", "10.0.0.1"},
+		{"web1.example.com", "principals", "deploy"},
+		{"web1.example.com", "services", "ssh:22"},
+		{"web1.example.com", "tags", "prod,web"},
+		{"web2.example.com", "addresses", "web2.example.com"},
+		{"web2.example.com", "services", "ssh:2222"},
+		{"web2.example.com", "tags", "a;b"},
+		{"web2.example.com", "principals", "www"},
+		{"db1.example.com", "common_name", "db1.example.com"},
+		{"db1.example.com", "principals", "postgres"},
+		{"db1.example.com", "tags", "db,prod"},
+		{"app1.example.com", "principals", "www"},
+		{"app1.example.com", "tags", "db,prod,web"},
+		{"bastion.example.com", "principals", "admin"},
+		{"bastion.example.com", "tags", ""},
+	} {
+		if value := attrs[test.host][test.key]; value != test.expected {
+			t.Errorf("%s %s %q, expected %q", test.host, test.key, value, test.expected)
+		}
+	}
+}
+
+func TestPlanHostImport(t *testing.T) {
+	hosts := []hoststore.Host{
+		{ID: "h1", Name: "web1.example.com", Tags: []string{"web"}},
+		{ID: "h2", Name: "db1.example.com", ExternalID: "i-2"},
+	}
+	mapping, err := hostImportMapping([]string{"common_name=name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []hostRecord{
+		{row: 2, attrs: map[string]string{"name": "web1.example.com", "tags": "web"}},
+		{row: 3, attrs: map[string]string{"name": "WEB1.example.com", "tags": "web;prod"}},
+		{row: 4, attrs: map[string]string{"name": "new.example.com", "services": "ssh;rdp:3390", "addresses": "10.0.0.9"}},
+		{row: 5, attrs: map[string]string{"name": "", "external_id": "i-2", "tags": "db"}},
+		{row: 6, attrs: map[string]string{"name": "bad.example.com", "services": "ftp"}},
+		{row: 7, attrs: map[string]string{"name": " "}},
+	}
+
+	results := planHostImport(records, hosts, mapping, ";")
+	actions := []string{}
+	for _, result := range results {
+		actions = append(actions, result.Action+":"+result.ID)
+	}
+
+	expected := []string{"unchanged:h1", "error:", "create:", "update:h2", "error:", "error:"}
+	if !reflect.DeepEqual(actions, expected) {
+		t.Fatalf("actions %q, expected %q", actions, expected)
+	}
+
+	created := results[2].host
+	services := []hoststore.Service{
+		{Scheme: "SSH", Address: "10.0.0.9", Port: 22},
+		{Scheme: "RDP", Address: "10.0.0.9", Port: 3390},
+	}
+	if !reflect.DeepEqual(created.Services, services) {
+		t.Errorf("services %+v", created.Services)
+	}
+
+	if tags := results[3].host.Tags; !reflect.DeepEqual(tags, []string{"db"}) {
+		t.Errorf("tags %q", tags)
+	}
+}
+
+func TestPlanHostImportMatching(t *testing.T) {
+	hosts := []hoststore.Host{
+		{ID: "h1", Name: "web1.example.com"},
+		{ID: "h2", Name: "db1.example.com", ExternalID: "i-2"},
+	}
+	mapping, err := hostImportMapping(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name    string
+		records []map[string]string
+		actions []string
+	}{
+		{
+			name:    "external ID",
+			records: []map[string]string{{"common_name": "renamed.example.com", "external_id": "i-2"}},
+			actions: []string{"update:h2"},
+		},
+		{
+			name:    "common name when external ID is not known",
+			records: []map[string]string{{"common_name": "web1.example.com", "external_id": "i-1"}},
+			actions: []string{"update:h1"},
+		},
+		{
+			name:    "common name of host with other external ID",
+			records: []map[string]string{{"common_name": "db1.example.com", "external_id": "i-3"}},
+			actions: []string{"create:"},
+		},
+		{
+			name: "same host by external ID and common name",
+			records: []map[string]string{
+				{"external_id": "i-2", "tags": "a"},
+				{"common_name": "db1.example.com", "tags": "b"},
+			},
+			actions: []string{"update:h2", "error:"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			records := []hostRecord{}
+			for i, attrs := range test.records {
+				records = append(records, hostRecord{row: i + 2, attrs: attrs})
+			}
+
+			actions := []string{}
+			for _, result := range planHostImport(records, hosts, mapping, ";") {
+				actions = append(actions, result.Action+":"+result.ID)
+			}
+
+			if !reflect.DeepEqual(actions, test.actions) {
+				t.Errorf("actions %q, expected %q", actions, test.actions)
+			}
+		})
+	}
+}
@@ -0,0 +1,512 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/hoststore"
+	"github.com/spf13/cobra"
+)
+
+type hostReconcileOptions struct {
+	provider string
+	stale    string
+	regions  []string
+	workers  int
+	apply    bool
+}
+
+// cloudInstance is the provider neutral view of exported instance
+type cloudInstance struct {
+	ID        string
+	Name      string
+	Region    string
+	Addresses []string
+	Tags      []string
+}
+
+type hostReconcileResult struct {
+	ExternalID string   `json:"external_id"`
+	CommonName string   `json:"common_name"`
+	State      string   `json:"state"`
+	Action     string   `json:"action"`
+	ID         string   `json:"id,omitempty"`
+	Changes    []string `json:"changes,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	host       *hoststore.Host
+}
+
+type awsTag struct {
+	Key   string `json:"Key"`
+	Value string `json:"Value"`
+}
+
+type awsExport struct {
+	Reservations []struct {
+		Instances []struct {
+			InstanceID       string `json:"InstanceId"`
+			PrivateIPAddress string `json:"PrivateIpAddress"`
+			PublicIPAddress  string `json:"PublicIpAddress"`
+			PrivateDNSName   string `json:"PrivateDnsName"`
+			PublicDNSName    string `json:"PublicDnsName"`
+			Placement        struct {
+				AvailabilityZone string `json:"AvailabilityZone"`
+			} `json:"Placement"`
+			Tags []awsTag `json:"Tags"`
+		} `json:"Instances"`
+	} `json:"Reservations"`
+}
+
+type azureExport []struct {
+	ID         string            `json:"id"`
+	VMID       string            `json:"vmId"`
+	Name       string            `json:"name"`
+	Location   string            `json:"location"`
+	PrivateIPs string            `json:"privateIps"`
+	PublicIPs  string            `json:"publicIps"`
+	FQDNs      string            `json:"fqdns"`
+	Tags       map[string]string `json:"tags"`
+}
+
+type gcpExport []struct {
+	ID                string            `json:"id"`
+	Name              string            `json:"name"`
+	Zone              string            `json:"zone"`
+	Labels            map[string]string `json:"labels"`
+	NetworkInterfaces []struct {
+		NetworkIP     string `json:"networkIP"`
+		AccessConfigs []struct {
+			NatIP string `json:"natIP"`
+		} `json:"accessConfigs"`
+	} `json:"networkInterfaces"`
+}
+
+// cloud provider names as stored to PrivX hosts
+var cloudProviders = map[string]string{
+	"aws":   "AWS",
+	"azure": "AZURE",
+	"gcp":   "GOOGLECLOUD",
+}
+
+//
+//
+func hostReconcileCmd() *cobra.Command {
+	options := hostReconcileOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "reconcile",
+		Short: "Reconcile hosts with cloud instance export",
+		Long: `Reconcile hosts with instances exported from cloud provider, no cloud access is needed.
+Supported exports are output of 'aws ec2 describe-instances', 'az vm list -d'
+and 'gcloud compute instances list --format=json'. Instance ID is used as external
+ID of the host, instance tags or labels are host tags as KEY=VALUE.
+
+Instances without host are missing and created, hosts which differ from the
+instance are drifted and updated. Instance tags are added to the existing host
+tags, replacing earlier values of the same keys, other host tags are kept.
+
+Hosts of the provider without instance are stale and disabled, deleted or kept
+depending on --stale. Only hosts with external ID in the regions of the export
+are stale, --region sets the regions explicitly. With --stale disable, disabled
+hosts whose instance is in the export are enabled again. Without --apply the
+plan is only printed.`,
+		Example: `
+	aws ec2 describe-instances > instances.json
+	privx-cli hosts reconcile [access flags] --provider aws instances.json
+	privx-cli hosts reconcile [access flags] --provider gcp --stale delete --apply instances.json
+	privx-cli hosts reconcile [access flags] --provider aws --region eu-west-1,eu-north-1 instances.json
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return hostReconcile(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.provider, "provider", "", "cloud provider of the export, aws, azure or gcp")
+	flags.StringVar(&options.stale, "stale", "disable", "action for stale hosts, disable, delete or keep")
+	flags.StringSliceVar(&options.regions, "region", []string{}, "regions covered by the export (default regions of the instances)")
+	flags.IntVar(&options.workers, "workers", 4, "number of concurrent requests")
+	flags.BoolVar(&options.apply, "apply", false, "apply the changes, by default only plan is shown")
+	cmd.MarkFlagRequired("provider")
+
+	return cmd
+}
+
+func hostReconcile(options hostReconcileOptions, args []string) error {
+	provider, ok := cloudProviders[strings.ToLower(options.provider)]
+	if !ok {
+		return fmt.Errorf("cloud provider is not supported: %s", options.provider)
+	}
+
+	switch options.stale {
+	case "disable", "delete", "keep":
+	default:
+		return fmt.Errorf("invalid action for stale hosts: %s", options.stale)
+	}
+
+	instances, err := readCloudExport(strings.ToLower(options.provider), args[0])
+	if err != nil {
+		return err
+	}
+
+	api := hoststore.New(curl())
+	hosts, err := allHosts(api)
+	if err != nil {
+		return err
+	}
+
+	results := planHostReconcile(provider, instances, hosts, options.stale, options.regions)
+	if !options.apply {
+		return stdout(results)
+	}
+
+	parallel(len(results), options.workers, func(i int) {
+		result := &results[i]
+
+		var err error
+		switch result.Action {
+		case "create":
+			result.ID, err = api.CreateHost(*result.host)
+		case "update":
+			err = api.UpdateHost(result.ID, result.host)
+		case "enable":
+			if len(result.Changes) > 0 {
+				err = api.UpdateHost(result.ID, result.host)
+			}
+			if err == nil {
+				err = api.UpdateDisabledHostStatus(result.ID, false)
+			}
+		case "disable":
+			err = api.UpdateDisabledHostStatus(result.ID, true)
+		case "delete":
+			err = api.DeleteHost(result.ID)
+		}
+
+		if err != nil {
+			result.Error = err.Error()
+		}
+	})
+
+	return stdout(results)
+}
+
+// planHostReconcile compares instances to hosts, hosts are stale only in the
+// regions covered by the export, regions of the instances by default
+func planHostReconcile(provider string, instances []cloudInstance, hosts []hoststore.Host, stale string, regions []string) []hostReconcileResult {
+	byExternalID := map[string]hoststore.Host{}
+	for _, host := range hosts {
+		if host.ExternalID != "" {
+			byExternalID[host.ExternalID] = host
+		}
+	}
+
+	results := []hostReconcileResult{}
+	exported := map[string]bool{}
+	covered := map[string]bool{}
+
+	for _, region := range regions {
+		covered[strings.ToLower(region)] = true
+	}
+
+	for _, instance := range instances {
+		exported[instance.ID] = true
+		if len(regions) == 0 {
+			covered[strings.ToLower(instance.Region)] = true
+		}
+
+		existing, found := byExternalID[instance.ID]
+		host := cloudHost(provider, instance, existing)
+
+		// hosts disabled as stale are enabled again once the instance returns
+		switch changes := hostChanges(existing, host); {
+		case found && stale == "disable" && hostDisabled(existing):
+			results = append(results, hostReconcileResult{
+				ExternalID: instance.ID,
+				CommonName: host.Name,
+				State:      "returned",
+				Action:     "enable",
+				ID:         existing.ID,
+				Changes:    changes,
+				host:       &host,
+			})
+		case !found:
+			results = append(results, hostReconcileResult{
+				ExternalID: instance.ID,
+				CommonName: host.Name,
+				State:      "missing",
+				Action:     "create",
+				host:       &host,
+			})
+		case len(changes) > 0:
+			results = append(results, hostReconcileResult{
+				ExternalID: instance.ID,
+				CommonName: host.Name,
+				State:      "drifted",
+				Action:     "update",
+				ID:         existing.ID,
+				Changes:    changes,
+				host:       &host,
+			})
+		}
+	}
+
+	for _, host := range hosts {
+		switch {
+		case !strings.EqualFold(host.CloudProvider, provider):
+			continue
+		case host.ExternalID == "" || exported[host.ExternalID]:
+			continue
+		case host.CloudProviderRegion == "" || !covered[strings.ToLower(host.CloudProviderRegion)]:
+			continue
+		}
+
+		action := stale
+		if stale == "disable" && hostDisabled(host) {
+			action = "keep"
+		}
+
+		results = append(results, hostReconcileResult{
+			ExternalID: host.ExternalID,
+			CommonName: host.Name,
+			State:      "stale",
+			Action:     action,
+			ID:         host.ID,
+		})
+	}
+
+	return results
+}
+
+// cloudHost applies the instance over existing host. Instance tags replace
+// host tags of the same keys, other tags and name of existing host are kept.
+// New hosts get SSH service at the first address.
+func cloudHost(provider string, instance cloudInstance, host hoststore.Host) hoststore.Host {
+	host.ExternalID = instance.ID
+	host.InstanceID = instance.ID
+	host.CloudProvider = provider
+	host.CloudProviderRegion = instance.Region
+	host.Tags = mergeCloudTags(host.Tags, instance.Tags)
+
+	if host.ID == "" {
+		host.Name = instance.Name
+	}
+
+	host.Addresses = []hoststore.Address{}
+	for _, address := range instance.Addresses {
+		host.Addresses = append(host.Addresses, hoststore.Address(address))
+	}
+
+	if host.ID == "" && len(host.Addresses) > 0 {
+		host.Services = []hoststore.Service{
+			{Scheme: "SSH", Address: host.Addresses[0], Port: defaultServicePorts["SSH"]},
+		}
+	}
+
+	return host
+}
+
+// hostChanges lists the fields which are different between hosts
+func hostChanges(before, after hoststore.Host) []string {
+	var a, b map[string]interface{}
+	if err := reshape(before, &a); err != nil {
+		return []string{err.Error()}
+	}
+	if err := reshape(after, &b); err != nil {
+		return []string{err.Error()}
+	}
+
+	changes := []string{}
+	for field, value := range b {
+		if !reflect.DeepEqual(a[field], value) && !(blank(a[field]) && blank(value)) {
+			changes = append(changes, field)
+		}
+	}
+	sort.Strings(changes)
+
+	return changes
+}
+
+// blank tells whether JSON value is null or empty
+func blank(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func readCloudExport(provider, name string) ([]cloudInstance, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch provider {
+	case "aws":
+		return awsInstances(data)
+	case "azure":
+		return azureInstances(data)
+	default:
+		return gcpInstances(data)
+	}
+}
+
+func awsInstances(data []byte) ([]cloudInstance, error) {
+	var export awsExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, err
+	}
+
+	instances := []cloudInstance{}
+	for _, reservation := range export.Reservations {
+		for _, vm := range reservation.Instances {
+			tags := map[string]string{}
+			for _, tag := range vm.Tags {
+				tags[tag.Key] = tag.Value
+			}
+
+			zone := vm.Placement.AvailabilityZone
+			instances = append(instances, cloudInstance{
+				ID:        vm.InstanceID,
+				Name:      firstOf(tags["Name"], vm.PrivateDNSName, vm.InstanceID),
+				Region:    strings.TrimRight(zone, "abcdefghijklmnopqrstuvwxyz"),
+				Addresses: nonEmpty(vm.PrivateIPAddress, vm.PublicIPAddress, vm.PublicDNSName),
+				Tags:      cloudTags(tags),
+			})
+		}
+	}
+
+	return instances, nil
+}
+
+func azureInstances(data []byte) ([]cloudInstance, error) {
+	var export azureExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, err
+	}
+
+	instances := []cloudInstance{}
+	for _, vm := range export {
+		addresses := []string{}
+		for _, list := range []string{vm.PrivateIPs, vm.PublicIPs, vm.FQDNs} {
+			addresses = append(addresses, splitList(list, ",")...)
+		}
+
+		instances = append(instances, cloudInstance{
+			ID:        firstOf(vm.VMID, vm.ID),
+			Name:      vm.Name,
+			Region:    vm.Location,
+			Addresses: addresses,
+			Tags:      cloudTags(vm.Tags),
+		})
+	}
+
+	return instances, nil
+}
+
+func gcpInstances(data []byte) ([]cloudInstance, error) {
+	var export gcpExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, err
+	}
+
+	instances := []cloudInstance{}
+	for _, vm := range export {
+		addresses := []string{}
+		for _, nic := range vm.NetworkInterfaces {
+			addresses = append(addresses, nonEmpty(nic.NetworkIP)...)
+			for _, access := range nic.AccessConfigs {
+				addresses = append(addresses, nonEmpty(access.NatIP)...)
+			}
+		}
+
+		zone := path.Base(vm.Zone)
+		if i := strings.LastIndex(zone, "-"); i > 0 {
+			zone = zone[:i]
+		}
+
+		instances = append(instances, cloudInstance{
+			ID:        vm.ID,
+			Name:      vm.Name,
+			Region:    zone,
+			Addresses: addresses,
+			Tags:      cloudTags(vm.Labels),
+		})
+	}
+
+	return instances, nil
+}
+
+// mergeCloudTags replaces the host tags having the same key as any of the
+// cloud tags and appends the rest, so that unchanged tags keep their order
+func mergeCloudTags(tags, cloud []string) []string {
+	byKey := map[string]string{}
+	for _, tag := range cloud {
+		key, _, _ := strings.Cut(tag, "=")
+		byKey[key] = tag
+	}
+
+	merged := []string{}
+	placed := map[string]bool{}
+	for _, tag := range tags {
+		key, _, _ := strings.Cut(tag, "=")
+		replacement, owned := byKey[key]
+		switch {
+		case !owned:
+			merged = append(merged, tag)
+		case !placed[key]:
+			merged = append(merged, replacement)
+			placed[key] = true
+		}
+	}
+
+	for _, tag := range cloud {
+		if key, _, _ := strings.Cut(tag, "="); !placed[key] {
+			merged = append(merged, tag)
+		}
+	}
+
+	return merged
+}
+
+// cloudTags converts instance tags to sorted KEY=VALUE host tags
+func cloudTags(tags map[string]string) []string {
+	result := []string{}
+	for key, value := range tags {
+		if value == "" {
+			result = append(result, key)
+		} else {
+			result = append(result, key+"="+value)
+		}
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+func nonEmpty(values ...string) []string {
+	result := []string{}
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,143 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/SSHcom/privx-sdk-go/api/hoststore"
+)
+
+func TestPlanHostReconcileStale(t *testing.T) {
+	instances := []cloudInstance{
+		{ID: "i-1", Name: "web", Region: "eu-west-1", Addresses: []string{"10.0.0.1"}},
+	}
+
+	for _, test := range []struct {
+		name    string
+		host    hoststore.Host
+		regions []string
+		action  string
+	}{
+		{
+			name:   "gone from exported region",
+			host:   hoststore.Host{ID: "h", ExternalID: "i-2", CloudProvider: "AWS", CloudProviderRegion: "eu-west-1"},
+			action: "disable",
+		},
+		{
+			name: "region not exported",
+			host: hoststore.Host{ID: "h", ExternalID: "i-2", CloudProvider: "AWS", CloudProviderRegion: "us-east-1"},
+		},
+		{
+			name:    "region given explicitly",
+			host:    hoststore.Host{ID: "h", ExternalID: "i-2", CloudProvider: "AWS", CloudProviderRegion: "us-east-1"},
+			regions: []string{"US-EAST-1"},
+			action:  "disable",
+		},
+		{
+			name: "no external ID",
+			host: hoststore.Host{ID: "h", CloudProvider: "AWS", CloudProviderRegion: "eu-west-1"},
+		},
+		{
+			name: "no region",
+			host: hoststore.Host{ID: "h", ExternalID: "i-2", CloudProvider: "AWS"},
+		},
+		{
+			name: "other provider",
+			host: hoststore.Host{ID: "h", ExternalID: "i-2", CloudProvider: "AZURE", CloudProviderRegion: "eu-west-1"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			action := ""
+			for _, result := range planHostReconcile("AWS", instances, []hoststore.Host{test.host}, "disable", test.regions) {
+				if result.State == "stale" {
+					action = result.Action
+				}
+			}
+
+			if action != test.action {
+				t.Errorf("stale action %q, expected %q", action, test.action)
+			}
+		})
+	}
+}
+
+func TestPlanHostReconcileKeepsTags(t *testing.T) {
+	instance := cloudInstance{ID: "i-1", Name: "web", Region: "eu-west-1", Addresses: []string{"10.0.0.1"}, Tags: []string{"env=prod"}}
+	host := hoststore.Host{
+		ID:                  "h",
+		Name:                "web.example.com",
+		ExternalID:          "i-1",
+		InstanceID:          "i-1",
+		CloudProvider:       "AWS",
+		CloudProviderRegion: "eu-west-1",
+		Addresses:           []hoststore.Address{"10.0.0.1"},
+		Tags:                []string{"team-a", "env=prod", "pci"},
+	}
+
+	results := planHostReconcile("AWS", []cloudInstance{instance}, []hoststore.Host{host}, "disable", nil)
+	if len(results) != 0 {
+		t.Fatalf("unexpected changes %+v", results)
+	}
+
+	instance.Tags = []string{"env=test"}
+	results = planHostReconcile("AWS", []cloudInstance{instance}, []hoststore.Host{host}, "disable", nil)
+	if len(results) != 1 || !reflect.DeepEqual(results[0].Changes, []string{"tags"}) {
+		t.Fatalf("expected tag drift, got %+v", results)
+	}
+
+	if tags := results[0].host.Tags; !reflect.DeepEqual(tags, []string{"team-a", "env=test", "pci"}) {
+		t.Errorf("tags %q", tags)
+	}
+}
+
+func TestMergeCloudTags(t *testing.T) {
+	for _, test := range []struct {
+		tags, cloud, expected []string
+	}{
+		{nil, []string{"a=1"}, []string{"a=1"}},
+		{[]string{"x", "a=0"}, []string{"a=1", "b"}, []string{"x", "a=1", "b"}},
+		{[]string{"a=0", "a=2"}, []string{"a=1"}, []string{"a=1"}},
+		{[]string{"x"}, []string{}, []string{"x"}},
+	} {
+		if merged := mergeCloudTags(test.tags, test.cloud); !reflect.DeepEqual(merged, test.expected) {
+			t.Errorf("mergeCloudTags(%q, %q) = %q, expected %q", test.tags, test.cloud, merged, test.expected)
+		}
+	}
+}
+
+func TestPlanHostReconcileEnablesReturned(t *testing.T) {
+	instance := cloudInstance{ID: "i-1", Name: "web", Region: "eu-west-1", Addresses: []string{"10.0.0.1"}}
+	host := hoststore.Host{
+		ID:                  "h",
+		Name:                "web",
+		ExternalID:          "i-1",
+		InstanceID:          "i-1",
+		CloudProvider:       "AWS",
+		CloudProviderRegion: "eu-west-1",
+		Addresses:           []hoststore.Address{"10.0.0.1"},
+		Disabled:            "TRUE",
+	}
+
+	for _, test := range []struct {
+		stale   string
+		actions []string
+	}{
+		{"disable", []string{"enable"}},
+		{"keep", []string{}},
+	} {
+		actions := []string{}
+		for _, result := range planHostReconcile("AWS", []cloudInstance{instance}, []hoststore.Host{host}, test.stale, nil) {
+			actions = append(actions, result.Action)
+		}
+
+		if !reflect.DeepEqual(actions, test.actions) {
+			t.Errorf("--stale %s actions %q, expected %q", test.stale, actions, test.actions)
+		}
+	}
+}
@@ -27,6 +27,22 @@ type hostOptions struct {
 	disabledStatus bool
 	limit          int
 	offset         int
+	keyword        string
+	source         string
+	commonNames    []string
+	addresses      []string
+	services       []string
+	tags           []string
+	accessGroups   []string
+	cloudProviders []string
+	ports          []int
+}
+
+type hostDeployOptions struct {
+	format string
+	out    string
+	list   bool
+	revoke bool
 }
 
 func init() {
@@ -68,6 +84,12 @@ func hostListCmd() *cobra.Command {
 	cmd.AddCommand(hostDisableCmd())
 	cmd.AddCommand(hostSettingListCmd())
 	cmd.AddCommand(hostsDeployCmd())
+	cmd.AddCommand(hostImportCmd())
+	cmd.AddCommand(hostReconcileCmd())
+	cmd.AddCommand(hostExportSSHConfigCmd())
+	cmd.AddCommand(hostExportKnownHostsCmd())
+	cmd.AddCommand(hostTagCmd())
+	cmd.AddCommand(hostAuditCmd())
 
 	return cmd
 }
@@ -92,15 +114,19 @@ func hostSearchCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "search",
 		Short: "Search hosts",
-		Long:  `Search hosts`,
+		Long: `Search hosts by flags and/or search object JSON file. Flags are merged with
+the JSON file, list values are appended and single values override the file.`,
 		Example: `
 	privx-cli hosts search [access flags] --offset <OFFSET> --sortkey <SORTKEY>
 	privx-cli hosts search [access flags] --limit <LIMIT> JSON-FILE
+	privx-cli hosts search [access flags] --keyword web --service ssh --port 22,2222
+	privx-cli hosts search [access flags] --tag env=prod --cloud-provider AWS --deployable=false
+	privx-cli hosts search [access flags] --access-group <ACCESS-GROUP-ID> JSON-FILE
 		`,
 		Args:         cobra.MaximumNArgs(1),
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return hostSearch(options, args)
+			return hostSearch(cmd, options, args)
 		},
 	}
 
@@ -110,23 +136,31 @@ func hostSearchCmd() *cobra.Command {
 	flags.StringVar(&options.filter, "filter", "", "filter hosts, possible values: accessible or configured")
 	flags.StringVar(&options.sortkey, "sortkey", "", "sort by specific object property")
 	flags.StringVar(&options.sortdir, "sortdir", "", "sort direction, ASC or DESC")
+	flags.StringVar(&options.keyword, "keyword", "", "free text keywords")
+	flags.StringSliceVar(&options.commonNames, "common-name", []string{}, "host common name(s)")
+	flags.StringSliceVar(&options.addresses, "address", []string{}, "host address(es)")
+	flags.IntSliceVar(&options.ports, "port", []int{}, "service port(s)")
+	flags.StringSliceVar(&options.services, "service", []string{}, "service type(s), ssh, rdp, web, vnc or db")
+	flags.StringSliceVar(&options.tags, "tag", []string{}, "host tag(s)")
+	flags.StringSliceVar(&options.accessGroups, "access-group", []string{}, "access group ID(s)")
+	flags.StringVar(&options.source, "source", "", "source ID of the hosts")
+	flags.BoolVar(&options.deployStatus, "deployable", false, "only deployable (true) or non-deployable (false) hosts")
+	flags.BoolVar(&options.disabledStatus, "disabled", false, "only disabled (true) or enabled (false) hosts")
+	flags.StringSliceVar(&options.cloudProviders, "cloud-provider", []string{}, "cloud provider(s), e.g. AWS, AZURE or GOOGLECLOUD")
 
 	return cmd
 }
 
-func hostSearch(options hostOptions, args []string) error {
-	var searchObject hoststore.HostSearchObject
+func hostSearch(cmd *cobra.Command, options hostOptions, args []string) error {
 	api := hoststore.New(curl())
 
-	if len(args) == 1 {
-		err := decodeJSON(args[0], &searchObject)
-		if err != nil {
-			return err
-		}
+	searchObject, err := hostSearchObject(cmd, options, args)
+	if err != nil {
+		return err
 	}
 
 	hosts, err := api.SearchHost(options.sortkey, strings.ToUpper(options.sortdir), options.filter,
-		options.offset, options.limit, &searchObject)
+		options.offset, options.limit, searchObject)
 	if err != nil {
 		return err
 	}
@@ -134,6 +168,48 @@ func hostSearch(options hostOptions, args []string) error {
 	return stdout(hosts)
 }
 
+// hostSearchObject merges search flags over the optional JSON file
+func hostSearchObject(cmd *cobra.Command, options hostOptions, args []string) (*hoststore.HostSearchObject, error) {
+	search := &hoststore.HostSearchObject{}
+	if len(args) == 1 {
+		err := decodeJSON(args[0], search)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, service := range options.services {
+		switch scheme := strings.ToUpper(service); scheme {
+		case "SSH", "RDP", "WEB", "VNC", "DB":
+			search.Service = append(search.Service, scheme)
+		default:
+			return nil, fmt.Errorf("service type does not exist: %s", service)
+		}
+	}
+
+	search.CommonName = append(search.CommonName, options.commonNames...)
+	search.Address = append(search.Address, options.addresses...)
+	search.Port = append(search.Port, options.ports...)
+	search.Tags = append(search.Tags, options.tags...)
+	search.AccessGroupIDs = append(search.AccessGroupIDs, options.accessGroups...)
+	search.CloudProviders = append(search.CloudProviders, options.cloudProviders...)
+
+	if options.keyword != "" {
+		search.Keywords = options.keyword
+	}
+	if options.source != "" {
+		search.SourceID = options.source
+	}
+	if cmd.Flags().Changed("deployable") {
+		search.Deployable = &options.deployStatus
+	}
+	if cmd.Flags().Changed("disabled") {
+		search.Disabled = &options.disabledStatus
+	}
+
+	return search, nil
+}
+
 //
 //
 func hostCreateCmd() *cobra.Command {
@@ -448,28 +524,39 @@ func hostSettingList() error {
 //
 //
 func hostsDeployCmd() *cobra.Command {
+	options := hostDeployOptions{}
+
 	cmd := &cobra.Command{
 		Use:   "deploy",
 		Short: "Creates target hosts deployment config",
-		Long:  `Creates target hosts deployment config`,
+		Long: `Creates target hosts deployment config. Host provisioning trusted client of
+the given name is created unless it exists already. Deployment config is printed
+as is (raw) or wrapped with the PrivX trust anchor into cloud-init user data,
+shell script or Ansible vars file. Files written by --out are readable only by
+the owner.`,
 		Example: `
 	privx-cli hosts deploy [access flags] <NAME>
+	privx-cli hosts deploy [access flags] --format cloud-init --out user-data.yml <NAME>
+	privx-cli hosts deploy [access flags] --format ansible --out roles/privx/vars/main.yml <NAME>
+	privx-cli hosts deploy [access flags] --list
+	privx-cli hosts deploy [access flags] --revoke <NAME>
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return hostDeploy(args)
+			return hostDeploy(options, args)
 		},
 	}
 
+	flags := cmd.Flags()
+	flags.StringVar(&options.format, "format", "raw", "output format, raw, cloud-init, shell or ansible")
+	flags.StringVar(&options.out, "out", "", "write deployment to file with 0600 permissions")
+	flags.BoolVar(&options.list, "list", false, "list host provisioning clients")
+	flags.BoolVar(&options.revoke, "revoke", false, "delete host provisioning client of the given name")
+
 	return cmd
 }
 
-func hostDeploy(args []string) error {
-	if len(args) < 1 {
-		return errors.New("requires name of deployment configuration as an argument")
-	}
-	name := args[0]
-
+func hostDeploy(options hostDeployOptions, args []string) error {
 	curl := curl()
 	store := userstore.New(curl)
 
@@ -478,7 +565,32 @@ func hostDeploy(args []string) error {
 		return err
 	}
 
-	cli := findClientID(seq, name)
+	if options.list {
+		return stdout(trustedClientListHelper(seq, hostProvisioningClient))
+	}
+
+	if len(args) < 1 {
+		return errors.New("requires name of deployment configuration as an argument")
+	}
+	name := args[0]
+
+	cli := findClientID(trustedClientListHelper(seq, hostProvisioningClient), name)
+
+	if options.revoke {
+		if cli == "" {
+			return fmt.Errorf("host provisioning client does not exist: %s", name)
+		}
+		if err := store.DeleteTrustedClient(cli); err != nil {
+			return err
+		}
+		fmt.Println(cli)
+		return nil
+	}
+
+	if !validDeployFormat(options.format) {
+		return fmt.Errorf("deployment format does not exist: %s", options.format)
+	}
+
 	if cli == "" {
 		cli, err = store.CreateTrustedClient(
 			userstore.HostProvisioning(name),
@@ -494,7 +606,16 @@ func hostDeploy(args []string) error {
 		return err
 	}
 
-	os.Stdout.Write(file)
+	bundle, err := deployBundle(options.format, name, file)
+	if err != nil {
+		return err
+	}
+
+	if options.out != "" {
+		return writeSecretFile(options.out, bundle)
+	}
+
+	os.Stdout.Write(bundle)
 	return nil
 }
 
@@ -506,3 +627,42 @@ func findClientID(seq []userstore.TrustedClient, name string) string {
 	}
 	return ""
 }
+
+// allHosts walks through all pages of hosts
+func allHosts(api *hoststore.HostStore) ([]hoststore.Host, error) {
+	hosts := []hoststore.Host{}
+
+	for offset := 0; ; offset += pageSize {
+		page, err := api.Hosts(offset, pageSize, "", "", "")
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, page...)
+
+		if len(page) < pageSize {
+			return hosts, nil
+		}
+	}
+}
+
+// hostDisabled tells whether access to the host is disabled
+func hostDisabled(host hoststore.Host) bool {
+	return host.Disabled != "" && !strings.EqualFold(host.Disabled, "FALSE")
+}
+
+// searchHosts walks through all pages of hosts matching the search object
+func searchHosts(api *hoststore.HostStore, filter string, search *hoststore.HostSearchObject) ([]hoststore.Host, error) {
+	hosts := []hoststore.Host{}
+
+	for offset := 0; ; offset += pageSize {
+		page, err := api.SearchHost("", "", filter, offset, pageSize, search)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, page...)
+
+		if len(page) < pageSize {
+			return hosts, nil
+		}
+	}
+}
@@ -0,0 +1,207 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/hoststore"
+	"github.com/spf13/cobra"
+)
+
+type hostTagOptions struct {
+	hostID  string
+	filter  string
+	withTag []string
+	tags    []string
+	workers int
+	dryRun  bool
+}
+
+type hostTagResult struct {
+	ID         string   `json:"id"`
+	CommonName string   `json:"common_name"`
+	Before     []string `json:"before"`
+	After      []string `json:"after"`
+	Action     string   `json:"action"`
+	Error      string   `json:"error,omitempty"`
+}
+
+//
+//
+func hostTagCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tag",
+		Short: "Add, remove or set tags of multiple hosts",
+		Long: `Add, remove or set tags of multiple hosts. Hosts are selected by IDs, search
+object JSON file, --filter or tags they already have. Selectors are combined,
+hosts given by ID are added to hosts matching the search. Each host is read,
+modified and written back concurrently, --dry-run previews the changes.`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(hostTagChangeCmd("add", "Add tags to hosts",
+		func(tags, change []string) []string { return uniqueStrings(append(tags, change...)) }))
+	cmd.AddCommand(hostTagChangeCmd("remove", "Remove tags from hosts",
+		func(tags, change []string) []string { return withoutStrings(tags, change) }))
+	cmd.AddCommand(hostTagChangeCmd("set", "Replace tags of hosts",
+		func(tags, change []string) []string { return uniqueStrings(change) }))
+
+	return cmd
+}
+
+func hostTagChangeCmd(action, short string, change func(tags, change []string) []string) *cobra.Command {
+	options := hostTagOptions{}
+
+	cmd := &cobra.Command{
+		Use:   action,
+		Short: short,
+		Long:  short + `. Hosts are selected by --id, search JSON file, --filter or --with-tag.`,
+		Example: `
+	privx-cli hosts tag ` + action + ` [access flags] --tag env=prod --id <HOST-ID>,<HOST-ID>
+	privx-cli hosts tag ` + action + ` [access flags] --tag env=prod --with-tag web --dry-run
+	privx-cli hosts tag ` + action + ` [access flags] --tag env=prod --filter accessible JSON-FILE
+		`,
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return hostTagChange(options, args, change)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringSliceVar(&options.tags, "tag", []string{}, "host tags, comma separated or repeated")
+	flags.StringVar(&options.hostID, "id", "", "host ID(s), separated by comma")
+	flags.StringVar(&options.filter, "filter", "", "filter hosts, possible values: accessible or configured")
+	flags.StringSliceVar(&options.withTag, "with-tag", []string{}, "select hosts having the given tags")
+	flags.IntVar(&options.workers, "workers", 4, "number of concurrent requests")
+	flags.BoolVar(&options.dryRun, "dry-run", false, "show the changes without updating hosts")
+	cmd.MarkFlagRequired("tag")
+
+	return cmd
+}
+
+func hostTagChange(options hostTagOptions, args []string, change func(tags, change []string) []string) error {
+	api := hoststore.New(curl())
+
+	ids, err := hostTagTargets(api, options, args)
+	if err != nil {
+		return err
+	}
+
+	results := make([]hostTagResult, len(ids))
+	parallel(len(ids), options.workers, func(i int) {
+		result := &results[i]
+		result.ID = ids[i]
+
+		host, err := api.Host(ids[i])
+		if err != nil {
+			result.Action, result.Error = "error", err.Error()
+			return
+		}
+
+		result.CommonName = host.Name
+		result.Before = append([]string{}, host.Tags...)
+		result.After = change(host.Tags, options.tags)
+
+		if sameStrings(result.Before, result.After) {
+			result.Action = "unchanged"
+			return
+		}
+
+		result.Action = "update"
+		if options.dryRun {
+			return
+		}
+
+		host.Tags = result.After
+		if err := api.UpdateHost(host.ID, host); err != nil {
+			result.Action, result.Error = "error", err.Error()
+		}
+	})
+
+	return stdout(results)
+}
+
+// hostTagTargets resolves IDs of hosts selected by the options
+func hostTagTargets(api *hoststore.HostStore, options hostTagOptions, args []string) ([]string, error) {
+	ids := []string{}
+	if options.hostID != "" {
+		ids = append(ids, strings.Split(options.hostID, ",")...)
+	}
+
+	if len(args) > 0 || options.filter != "" || len(options.withTag) > 0 {
+		var searchObject hoststore.HostSearchObject
+		if len(args) == 1 {
+			if err := decodeJSON(args[0], &searchObject); err != nil {
+				return nil, err
+			}
+		}
+
+		hosts, err := searchHosts(api, options.filter, &searchObject)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, host := range hosts {
+			if hasTags(host, options.withTag) {
+				ids = append(ids, host.ID)
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, errors.New("no hosts selected, use --id, --filter, --with-tag or search JSON file")
+	}
+
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, id := range ids {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	return unique, nil
+}
+
+func withoutStrings(values, remove []string) []string {
+	drop := map[string]bool{}
+	for _, value := range remove {
+		drop[value] = true
+	}
+
+	result := []string{}
+	for _, value := range values {
+		if !drop[value] {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// sameStrings compares string sets ignoring order
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	x := append([]string{}, a...)
+	y := append([]string{}, b...)
+	sort.Strings(x)
+	sort.Strings(y)
+
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
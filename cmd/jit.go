@@ -0,0 +1,390 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/spf13/cobra"
+)
+
+type jitOptions struct {
+	state    string
+	interval time.Duration
+	once     bool
+}
+
+// jitGrant is a time-boxed role grant waiting for expiry
+type jitGrant struct {
+	UserID    string    `json:"user_id"`
+	RoleID    string    `json:"role_id"`
+	GrantedAt time.Time `json:"granted_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type jitPending struct {
+	jitGrant
+	Remaining string `json:"remaining"`
+}
+
+// how long to wait for other process to release the state file
+const jitLockTimeout = 10 * time.Second
+
+func init() {
+	rootCmd.AddCommand(jitCmd())
+}
+
+//
+//
+func jitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "jit",
+		Short: "Manage expiry of time-boxed role grants",
+		Long: `Manage expiry of time-boxed role grants made with users roles --grant --for.
+Grants are recorded to local state file and revoked by jit daemon once expired.`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(jitDaemonCmd())
+	cmd.AddCommand(jitListCmd())
+
+	return cmd
+}
+
+//
+//
+func jitDaemonCmd() *cobra.Command {
+	options := jitOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Revoke expired role grants on schedule",
+		Long: `Revoke expired role grants on schedule. Grants failed to revoke are retried
+on the next round.`,
+		Example: `
+	privx-cli jit daemon [access flags]
+	privx-cli jit daemon [access flags] --interval 30s --state /var/lib/privx-cli/jit.json
+	privx-cli jit daemon [access flags] --once
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return jitDaemon(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.state, "state", jitDefaultState(), "state file of time-boxed grants")
+	flags.DurationVar(&options.interval, "interval", time.Minute, "how often expired grants are revoked")
+	flags.BoolVar(&options.once, "once", false, "revoke expired grants once and exit")
+
+	return cmd
+}
+
+//
+//
+func jitListCmd() *cobra.Command {
+	options := jitOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List pending expirations of role grants",
+		Long:  `List pending expirations of role grants`,
+		Example: `
+	privx-cli jit list
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return jitList(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.state, "state", jitDefaultState(), "state file of time-boxed grants")
+
+	return cmd
+}
+
+func jitDaemon(options jitOptions) error {
+	for {
+		if err := jitExpire(options.state); err != nil {
+			if options.once {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+
+		if options.once {
+			return nil
+		}
+		time.Sleep(options.interval)
+	}
+}
+
+// jitExpire revokes expired grants, grants failed to revoke stay in state.
+// Revoking is done without holding the lock of the state file.
+func jitExpire(state string) error {
+	api := rolestore.New(curl())
+
+	grants, err := readJITState(state)
+	if err != nil {
+		return err
+	}
+
+	revoked := []jitGrant{}
+	for _, grant := range grants {
+		if time.Now().Before(grant.ExpiresAt) {
+			continue
+		}
+
+		if err := api.RevokeUserRole(grant.UserID, grant.RoleID); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: revoking role %s from user %s: %v\n", grant.RoleID, grant.UserID, err)
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "revoked role %s from user %s, expired at %s\n",
+			grant.RoleID, grant.UserID, grant.ExpiresAt.Format(time.RFC3339))
+		revoked = append(revoked, grant)
+	}
+
+	if len(revoked) == 0 {
+		return nil
+	}
+
+	// grants renewed meanwhile have different expiry and are kept
+	return updateJITState(state, func(grants []jitGrant) []jitGrant {
+		pending := []jitGrant{}
+		for _, grant := range grants {
+			done := false
+			for _, r := range revoked {
+				done = done || (grant.UserID == r.UserID && grant.RoleID == r.RoleID && grant.ExpiresAt.Equal(r.ExpiresAt))
+			}
+			if !done {
+				pending = append(pending, grant)
+			}
+		}
+		return pending
+	})
+}
+
+func jitList(options jitOptions) error {
+	grants, err := readJITState(options.state)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(grants, func(i, j int) bool { return grants[i].ExpiresAt.Before(grants[j].ExpiresAt) })
+
+	pending := []jitPending{}
+	for _, grant := range grants {
+		remaining := time.Until(grant.ExpiresAt).Round(time.Second)
+		if remaining < 0 {
+			remaining = 0
+		}
+		pending = append(pending, jitPending{jitGrant: grant, Remaining: remaining.String()})
+	}
+
+	return stdout(pending)
+}
+
+// recordJITGrants records grants of the roles to expire after the duration,
+// earlier expiry of the same grant is replaced
+func recordJITGrants(state, userID string, roles []string, duration time.Duration) error {
+	now := time.Now().UTC()
+
+	return updateJITState(state, func(grants []jitGrant) []jitGrant {
+		grants = forgetJITGrants(grants, userID, roles)
+		for _, role := range roles {
+			grants = append(grants, jitGrant{
+				UserID:    userID,
+				RoleID:    role,
+				GrantedAt: now,
+				ExpiresAt: now.Add(duration),
+			})
+		}
+		return grants
+	})
+}
+
+// permanentGrants returns the roles which the user already holds explicitly
+// without recorded expiry, time-boxing them would revoke earlier access
+func permanentGrants(api *rolestore.RoleStore, state, userID string, roles []string) ([]string, error) {
+	held, err := explicitRoles(api, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	grants, err := readJITState(state)
+	if err != nil {
+		return nil, err
+	}
+
+	timeBoxed := map[string]bool{}
+	for _, grant := range grants {
+		if grant.UserID == userID {
+			timeBoxed[grant.RoleID] = true
+		}
+	}
+
+	permanent := map[string]bool{}
+	for _, role := range held {
+		permanent[role.ID] = !timeBoxed[role.ID]
+	}
+
+	found := []string{}
+	for _, role := range roles {
+		if permanent[role] {
+			found = append(found, role)
+		}
+	}
+
+	return uniqueStrings(found), nil
+}
+
+func forgetJITGrants(grants []jitGrant, userID string, roles []string) []jitGrant {
+	drop := map[string]bool{}
+	for _, role := range roles {
+		drop[role] = true
+	}
+
+	kept := []jitGrant{}
+	for _, grant := range grants {
+		if grant.UserID != userID || !drop[grant.RoleID] {
+			kept = append(kept, grant)
+		}
+	}
+	return kept
+}
+
+func jitDefaultState() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "privx-cli", "jit.json")
+}
+
+func readJITState(state string) ([]jitGrant, error) {
+	grants := []jitGrant{}
+
+	data, err := os.ReadFile(state)
+	if errors.Is(err, os.ErrNotExist) {
+		return grants, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &grants); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", state, err)
+	}
+
+	return grants, nil
+}
+
+// updateJITState modifies state file under lock, so that grants and the
+// daemon do not overwrite each other
+func updateJITState(state string, update func([]jitGrant) []jitGrant) error {
+	if err := os.MkdirAll(filepath.Dir(state), 0700); err != nil {
+		return err
+	}
+
+	lock := state + ".lock"
+	if err := lockJITState(lock); err != nil {
+		return fmt.Errorf("cannot lock state file %s: %w", state, err)
+	}
+	defer os.Remove(lock)
+
+	grants, err := readJITState(state)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(update(grants), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	temp := state + ".tmp"
+	if err := writeSecretFile(temp, data); err != nil {
+		return err
+	}
+
+	return os.Rename(temp, state)
+}
+
+// lockJITState creates lock file holding the process ID, lock of process
+// no longer running is removed
+func lockJITState(lock string) error {
+	for deadline := time.Now().Add(jitLockTimeout); ; {
+		file, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_, err = fmt.Fprint(file, os.Getpid())
+			file.Close()
+			return err
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return err
+		}
+
+		if staleJITLock(lock) {
+			os.Remove(lock)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// staleJITLock tells whether process holding the lock is gone. Lock without
+// process ID is stale once older than the lock timeout.
+func staleJITLock(lock string) bool {
+	info, err := os.Stat(lock)
+	if err != nil {
+		return false
+	}
+
+	data, err := os.ReadFile(lock)
+	if err != nil {
+		return false
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Since(info.ModTime()) > jitLockTimeout
+	}
+
+	return !processRunning(pid)
+}
+
+func processRunning(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	// on Windows the process is found only if it is running
+	if runtime.GOOS == "windows" {
+		return true
+	}
+
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, os.ErrPermission)
+}
@@ -0,0 +1,71 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStaleJITLock(t *testing.T) {
+	dir := t.TempDir()
+	lock := filepath.Join(dir, "jit.json.lock")
+
+	for _, test := range []struct {
+		name    string
+		content string
+		age     time.Duration
+		stale   bool
+	}{
+		{"running process", fmt.Sprint(os.Getpid()), 0, false},
+		{"finished process", "2147483646", 0, true},
+		{"new lock without process", "", 0, false},
+		{"old lock without process", "", 2 * jitLockTimeout, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if err := os.WriteFile(lock, []byte(test.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			modified := time.Now().Add(-test.age)
+			if err := os.Chtimes(lock, modified, modified); err != nil {
+				t.Fatal(err)
+			}
+
+			if stale := staleJITLock(lock); stale != test.stale {
+				t.Errorf("stale %v, expected %v", stale, test.stale)
+			}
+		})
+	}
+}
+
+func TestUpdateJITStateRemovesStaleLock(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "jit.json")
+	if err := os.WriteFile(state+".lock", []byte("2147483646"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	grant := jitGrant{UserID: "u", RoleID: "r", ExpiresAt: time.Now().Add(time.Hour).UTC()}
+	err := updateJITState(state, func(grants []jitGrant) []jitGrant { return append(grants, grant) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grants, err := readJITState(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grants) != 1 || grants[0].RoleID != "r" {
+		t.Errorf("unexpected state %+v", grants)
+	}
+
+	if _, err := os.Stat(state + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock file is left: %v", err)
+	}
+}
@@ -0,0 +1,321 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/SSHcom/privx-sdk-go/api/userstore"
+	"github.com/spf13/cobra"
+)
+
+type localUserImportOptions struct {
+	separator      string
+	workers        int
+	dryRun         bool
+	resetPasswords bool
+	policy         passwordPolicy
+	credentials    credentialOptions
+}
+
+type localUserImportResult struct {
+	Row      int      `json:"row"`
+	Username string   `json:"username"`
+	Action   string   `json:"action"`
+	ID       string   `json:"id,omitempty"`
+	Password bool     `json:"password_generated"`
+	Roles    []string `json:"roles_granted,omitempty"`
+	Error    string   `json:"error,omitempty"`
+	fields   map[string]string
+	tags     []string
+	roles    []string
+	password string
+}
+
+// local user fields which can be imported besides username, roles and tags
+var localUserImportFields = []string{"email", "full_name", "job_title", "company", "department", "telephone", "comment"}
+
+//
+//
+func localUserImportCmd() *cobra.Command {
+	options := localUserImportOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Create or update local users from CSV file",
+		Long: `Create or update local users from CSV file with username column and optional
+email, full_name, job_title, company, department, telephone, comment, roles and
+tags columns. Roles are given by ID or name, roles and tags are separated by
+semicolon and added to the existing ones.
+
+New users get random password per the password policy. Generated credentials are
+never printed, they are written to file encrypted with age or PGP, or stored as
+vault secrets named with --vault-prefix and the username. Passwords of existing
+users are reset only with --reset-passwords.`,
+		Example: `
+	privx-cli local-users import [access flags] --dry-run users.csv
+	privx-cli local-users import [access flags] --out credentials.csv.age --age-recipient age1... users.csv
+	privx-cli local-users import [access flags] --out credentials.csv.asc --pgp-recipient ops@example.com users.csv
+	privx-cli local-users import [access flags] --vault-prefix local-users/ --allow-read-to <ROLE-ID> --allow-write-to <ROLE-ID> users.csv
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return localUserImport(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.separator, "separator", ";", "separator of list values")
+	flags.IntVar(&options.workers, "workers", 4, "number of concurrent requests")
+	flags.BoolVar(&options.dryRun, "dry-run", false, "show the plan without changing users")
+	flags.BoolVar(&options.resetPasswords, "reset-passwords", false, "generate new passwords for existing users")
+	options.policy.flags(flags)
+	options.credentials.flags(flags)
+
+	return cmd
+}
+
+func localUserImport(options localUserImportOptions, args []string) error {
+	if !options.dryRun {
+		if err := options.policy.validate(); err != nil {
+			return err
+		}
+		if err := options.credentials.validate(); err != nil {
+			return err
+		}
+	}
+
+	results, err := readLocalUserImport(args[0], options.separator)
+	if err != nil {
+		return err
+	}
+
+	roles := rolestore.New(curl())
+	lookup, err := roleIDs(roles)
+	if err != nil {
+		return err
+	}
+
+	existing, err := allLocalUsers()
+	if err != nil {
+		return err
+	}
+	ids := map[string]string{}
+	for _, user := range existing {
+		ids[strings.ToLower(user.Username)] = user.ID
+	}
+
+	seen := map[string]int{}
+	for i := range results {
+		result := &results[i]
+		if result.Action != "" {
+			continue
+		}
+
+		key := strings.ToLower(result.Username)
+		if first, ok := seen[key]; ok {
+			result.Action, result.Error = "error", fmt.Sprintf("duplicate of row %d", first)
+			continue
+		}
+		seen[key] = result.Row
+
+		for j, role := range result.roles {
+			id, ok := lookup(role)
+			if !ok {
+				result.Action, result.Error = "error", "role does not exist: "+role
+				break
+			}
+			result.roles[j] = id
+		}
+		if result.Action != "" {
+			continue
+		}
+
+		if id, ok := ids[key]; ok {
+			result.Action, result.ID = "update", id
+			result.Password = options.resetPasswords
+		} else {
+			result.Action, result.Password = "create", true
+		}
+	}
+
+	if options.dryRun {
+		return stdout(results)
+	}
+
+	api := userstore.New(curl())
+	parallel(len(results), options.workers, func(i int) {
+		result := &results[i]
+		if result.Action != "create" && result.Action != "update" {
+			return
+		}
+
+		if err := applyLocalUserImport(api, roles, result); err != nil {
+			result.Error = err.Error()
+			result.Password = false
+			return
+		}
+
+		if result.Password {
+			password, err := options.policy.generate()
+			if err != nil {
+				result.Error = err.Error()
+				result.Password = false
+			}
+			result.password = password
+		}
+	})
+
+	setLocalUserPasswords(results, options)
+
+	if err := stdout(results); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d rows failed", failed)
+	}
+
+	return nil
+}
+
+// applyLocalUserImport creates or updates the user and grants the roles
+func applyLocalUserImport(api *userstore.UserStore, roles *rolestore.RoleStore, result *localUserImportResult) error {
+	user := map[string]interface{}{}
+
+	if result.Action == "update" {
+		current, err := api.LocalUser(result.ID)
+		if err != nil {
+			return err
+		}
+		if err := reshape(current, &user); err != nil {
+			return err
+		}
+	}
+
+	user["username"] = result.Username
+	for field, value := range result.fields {
+		user[field] = value
+	}
+
+	tags := []string{}
+	if current, ok := user["tags"].([]interface{}); ok {
+		for _, tag := range current {
+			tags = append(tags, fmt.Sprint(tag))
+		}
+	}
+	user["tags"] = uniqueStrings(append(tags, result.tags...))
+
+	var local userstore.LocalUser
+	if err := reshape(user, &local); err != nil {
+		return err
+	}
+
+	if result.Action == "create" {
+		id, err := api.CreateLocalUser(local)
+		if err != nil {
+			return err
+		}
+		result.ID = id
+	} else if err := api.UpdateLocalUser(result.ID, &local); err != nil {
+		return err
+	}
+
+	granted := map[string]bool{}
+	if result.Action == "update" {
+		explicit, err := explicitRoles(roles, result.ID)
+		if err != nil {
+			return err
+		}
+		for _, role := range explicit {
+			granted[role.ID] = true
+		}
+	}
+
+	for _, role := range uniqueStrings(result.roles) {
+		if granted[role] {
+			continue
+		}
+		if err := roles.GrantUserRole(result.ID, role); err != nil {
+			return fmt.Errorf("granting role %s: %w", role, err)
+		}
+		result.Roles = append(result.Roles, role)
+	}
+
+	return nil
+}
+
+// setLocalUserPasswords stores generated credentials and sets them as passwords
+func setLocalUserPasswords(results []localUserImportResult, options localUserImportOptions) {
+	pending := []int{}
+	credentials := []credential{}
+	for i, result := range results {
+		if result.password != "" {
+			pending = append(pending, i)
+			credentials = append(credentials, credential{UserID: result.ID, Username: result.Username, Password: result.password})
+		}
+	}
+
+	for n, err := range options.credentials.apply(credentials, options.workers) {
+		if err != nil {
+			results[pending[n]].Error = err.Error()
+			results[pending[n]].Password = false
+		}
+	}
+}
+
+func readLocalUserImport(name, separator string) ([]localUserImportResult, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	input, err := readCSVTable(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := input.require("username"); err != nil {
+		return nil, err
+	}
+
+	results := []localUserImportResult{}
+	for _, record := range input.records {
+		result := localUserImportResult{
+			Row:      record.row,
+			Username: record.cells["username"],
+			fields:   map[string]string{},
+			roles:    splitList(record.cells["roles"], separator),
+			tags:     splitList(record.cells["tags"], separator),
+		}
+
+		for _, field := range localUserImportFields {
+			if value := record.cells[field]; value != "" {
+				result.fields[field] = value
+			}
+		}
+
+		if result.Username == "" {
+			result.Action, result.Error = "error", "username is required"
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
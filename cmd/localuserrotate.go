@@ -0,0 +1,164 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/userstore"
+	"github.com/spf13/cobra"
+)
+
+type localUserRotateOptions struct {
+	userID      string
+	tags        []string
+	workers     int
+	dryRun      bool
+	policy      passwordPolicy
+	credentials credentialOptions
+}
+
+type localUserRotation struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+//
+//
+func localUserRotatePasswordCmd() *cobra.Command {
+	options := localUserRotateOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "rotate-password",
+		Short: "Rotate local user passwords",
+		Long: `Rotate passwords of local users given by ID or by tag. New passwords are generated
+per the password policy and never printed. Password of a single user is stored to
+the vault secret given with --store-secret, passwords of many users to vault
+secrets named with --vault-prefix and the username, or to file encrypted with age
+or PGP. Passwords are stored before they are changed and the earlier secret is
+restored if the change fails. User ID's are separated by commas when using
+multiple values, see example`,
+		Example: `
+	privx-cli local-users rotate-password [access flags] --id <USER-ID> --store-secret break-glass --allow-read-to <ROLE-ID> --allow-write-to <ROLE-ID>
+	privx-cli local-users rotate-password [access flags] --tag break-glass --vault-prefix break-glass/ --allow-read-to <ROLE-ID> --allow-write-to <ROLE-ID>
+	privx-cli local-users rotate-password [access flags] --id <USER-ID>,<USER-ID> --out passwords.csv.age --age-recipient age1...
+	privx-cli local-users rotate-password [access flags] --tag break-glass --dry-run
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return localUserRotatePassword(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.userID, "id", "", "user ID")
+	flags.StringSliceVar(&options.tags, "tag", []string{}, "rotate passwords of users with any of the tags")
+	flags.StringVar(&options.credentials.secretName, "store-secret", "", "store password of single user to vault secret")
+	flags.IntVar(&options.workers, "workers", 4, "number of concurrent requests")
+	flags.BoolVar(&options.dryRun, "dry-run", false, "list the users without changing passwords")
+	options.policy.flags(flags)
+	options.credentials.flags(flags)
+
+	return cmd
+}
+
+func localUserRotatePassword(options localUserRotateOptions) error {
+	if options.userID == "" && len(options.tags) == 0 {
+		return fmt.Errorf("give users with --id or --tag")
+	}
+
+	if !options.dryRun {
+		if err := options.policy.validate(); err != nil {
+			return err
+		}
+		if err := options.credentials.validate(); err != nil {
+			return err
+		}
+	}
+
+	users, err := localUsersToRotate(options)
+	if err != nil {
+		return err
+	}
+
+	if options.credentials.secretName != "" && len(users) > 1 {
+		return fmt.Errorf("--store-secret stores password of single user, %d users given, use --vault-prefix", len(users))
+	}
+
+	if options.dryRun {
+		return stdout(users)
+	}
+
+	credentials := []credential{}
+	for i := range users {
+		password, err := options.policy.generate()
+		if err != nil {
+			return err
+		}
+		credentials = append(credentials, credential{UserID: users[i].ID, Username: users[i].Username, Password: password})
+	}
+
+	failed := 0
+	for i, err := range options.credentials.apply(credentials, options.workers) {
+		if err != nil {
+			users[i].Status, users[i].Error = "error", err.Error()
+			failed++
+		} else {
+			users[i].Status = "rotated"
+		}
+	}
+
+	if err := stdout(users); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d password rotations failed", failed)
+	}
+
+	return nil
+}
+
+// localUsersToRotate lists users given by ID and users having any of the tags
+func localUsersToRotate(options localUserRotateOptions) ([]localUserRotation, error) {
+	users := []localUserRotation{}
+	seen := map[string]bool{}
+
+	if options.userID != "" {
+		api := userstore.New(curl())
+		for _, id := range strings.Split(options.userID, ",") {
+			user, err := api.LocalUser(id)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[user.ID] {
+				seen[user.ID] = true
+				users = append(users, localUserRotation{ID: user.ID, Username: user.Username, Status: "planned"})
+			}
+		}
+	}
+
+	if len(options.tags) > 0 {
+		all, err := allLocalUsers()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, user := range all {
+			tagged := len(withoutStrings(user.Tags, options.tags)) < len(user.Tags)
+			if tagged && !seen[user.ID] {
+				seen[user.ID] = true
+				users = append(users, localUserRotation{ID: user.ID, Username: user.Username, Status: "planned"})
+			}
+		}
+	}
+
+	return users, nil
+}
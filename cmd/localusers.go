@@ -55,6 +55,8 @@ func localUserListCmd() *cobra.Command {
 	cmd.AddCommand(localUserUpdateCmd())
 	cmd.AddCommand(localUserDeleteCmd())
 	cmd.AddCommand(localUserUpdatePasswordCmd())
+	cmd.AddCommand(localUserImportCmd())
+	cmd.AddCommand(localUserRotatePasswordCmd())
 
 	return cmd
 }
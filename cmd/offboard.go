@@ -0,0 +1,205 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	authApi "github.com/SSHcom/privx-sdk-go/api/auth"
+	"github.com/SSHcom/privx-sdk-go/api/connectionmanager"
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/SSHcom/privx-sdk-go/api/userstore"
+	"github.com/SSHcom/privx-sdk-go/api/vault"
+	"github.com/spf13/cobra"
+)
+
+type offboardOptions struct {
+	userID string
+	dryRun bool
+}
+
+// offboardAction is an entry of offboarding receipt
+type offboardAction struct {
+	Step   string `json:"step"`
+	Target string `json:"target,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type offboardReceipt struct {
+	UserID      string           `json:"user_id"`
+	User        string           `json:"user"`
+	DryRun      bool             `json:"dry_run"`
+	StartedAt   string           `json:"started_at"`
+	FinishedAt  string           `json:"finished_at"`
+	Actions     []offboardAction `json:"actions"`
+	UserSecrets []string         `json:"user_secrets"`
+}
+
+//
+//
+func userOffboardCmd() *cobra.Command {
+	options := offboardOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "offboard",
+		Short: "Remove all access of the user",
+		Long: `Remove all access of the user: revoke explicit roles, terminate sessions and
+connections, delete authorized keys, unpair mobile devices and delete local user
+account. Steps already done are skipped, so the command can be re-run safely.
+Personal user secrets are listed for manual handling. Prints JSON receipt of
+every action, --dry-run previews the actions.`,
+		Example: `
+	privx-cli users offboard [access flags] --id <USER-ID> --dry-run
+	privx-cli users offboard [access flags] --id <USER-ID> > receipt.json
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return userOffboard(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.userID, "id", "", "user ID")
+	flags.BoolVar(&options.dryRun, "dry-run", false, "preview the actions without changing anything")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+func userOffboard(options offboardOptions) error {
+	id := options.userID
+	receipt := offboardReceipt{
+		UserID:      id,
+		DryRun:      options.dryRun,
+		StartedAt:   time.Now().UTC().Format(time.RFC3339),
+		Actions:     []offboardAction{},
+		UserSecrets: []string{},
+	}
+
+	failed := 0
+	act := func(step, target string, do func() error) {
+		action := offboardAction{Step: step, Target: target, Status: "planned"}
+		if !options.dryRun {
+			if err := do(); err != nil {
+				action.Status, action.Error = "error", err.Error()
+				failed++
+			} else {
+				action.Status = "done"
+			}
+		}
+		receipt.Actions = append(receipt.Actions, action)
+	}
+	fail := func(step string, err error) {
+		receipt.Actions = append(receipt.Actions, offboardAction{Step: step, Status: "error", Error: err.Error()})
+		failed++
+	}
+
+	roles := rolestore.New(curl())
+	auth := authApi.New(curl())
+
+	user, err := roles.User(id)
+	if err != nil {
+		return err
+	}
+	receipt.User = user.Principal
+
+	if explicit, err := explicitRoles(roles, id); err != nil {
+		fail("revoke role", err)
+	} else {
+		for _, role := range explicit {
+			role := role
+			act("revoke role", role.Name, func() error { return roles.RevokeUserRole(id, role.ID) })
+		}
+	}
+
+	act("terminate sessions", "", func() error { return auth.TerminateUserSessions(id) })
+
+	conns, err := searchConnections(connectionmanager.ConnectionSearch{Status: []string{"CONNECTED"}, UserID: []string{id}})
+	if err != nil {
+		fail("terminate connections", err)
+	} else if len(conns) > 0 {
+		act("terminate connections", fmt.Sprintf("%d connections", len(conns)), func() error {
+			return connectionmanager.New(curl()).TerminateConnectionsByUser(id)
+		})
+	}
+
+	if keys, err := roles.AuthorizedKeys(id); err != nil {
+		fail("delete authorized key", err)
+	} else {
+		for _, key := range keys {
+			key := key
+			act("delete authorized key", firstOf(key.Name, key.ID), func() error {
+				return roles.DeleteAuthorizedKey(id, key.ID)
+			})
+		}
+	}
+
+	if devices, err := auth.GetUserPairedDevices(id); err != nil {
+		fail("unpair device", err)
+	} else {
+		for _, device := range devices {
+			device := device
+			act("unpair device", firstOf(device.Name, device.ID), func() error {
+				return auth.UnpairUserDevice(id, device.ID)
+			})
+		}
+	}
+
+	local := userstore.New(curl())
+	if account, err := local.LocalUser(id); err != nil {
+		if !isNotFound(err) {
+			fail("delete local user", err)
+		}
+	} else if account != nil && account.ID != "" {
+		act("delete local user", account.Username, func() error { return local.DeleteLocalUser(id) })
+	}
+
+	store := vault.New(curl())
+	for offset := 0; ; offset += pageSize {
+		secrets, err := store.UserSecrets(vault.SecretID{OwnerID: id}, offset, pageSize)
+		if err != nil {
+			fail("list user secrets", err)
+			break
+		}
+
+		for _, secret := range secrets {
+			receipt.UserSecrets = append(receipt.UserSecrets, secret.Name)
+		}
+		if len(secrets) < pageSize {
+			break
+		}
+	}
+
+	receipt.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+	if err := stdout(receipt); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d offboarding actions failed", failed)
+	}
+
+	return nil
+}
+
+func explicitRoles(api *rolestore.RoleStore, userID string) ([]rolestore.Role, error) {
+	roles, err := api.UserRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	explicit := []rolestore.Role{}
+	for _, role := range roles {
+		if role.Explicit {
+			explicit = append(explicit, role)
+		}
+	}
+
+	return explicit, nil
+}
@@ -0,0 +1,312 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/connectionmanager"
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/spf13/cobra"
+)
+
+type reviewOptions struct {
+	out        string
+	signingKey string
+	workers    int
+	dryRun     bool
+}
+
+type reviewDecision struct {
+	Row    int    `json:"row"`
+	UserID string `json:"user_id"`
+	User   string `json:"user"`
+	RoleID string `json:"role_id"`
+	Role   string `json:"role"`
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+type reviewSummary struct {
+	Input       string           `json:"input"`
+	InputSHA256 string           `json:"input_sha256"`
+	AppliedBy   string           `json:"applied_by"`
+	AppliedAt   string           `json:"applied_at"`
+	DryRun      bool             `json:"dry_run"`
+	Counts      map[string]int   `json:"counts"`
+	Decisions   []reviewDecision `json:"decisions"`
+	Digest      string           `json:"digest,omitempty"`
+	Signature   string           `json:"signature,omitempty"`
+}
+
+// columns of access review CSV
+var reviewColumns = []string{"user_id", "user", "full_name", "role_id", "role", "granted_by", "last_connection", "decision"}
+
+func init() {
+	rootCmd.AddCommand(reviewCmd())
+}
+
+//
+//
+func reviewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "review",
+		Short: "Access review campaigns",
+		Long: `Export explicit role grants for access review and revoke grants marked by
+reviewers. Reviewers fill in the decision column with keep or revoke.`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(reviewExportCmd())
+	cmd.AddCommand(reviewApplyCmd())
+
+	return cmd
+}
+
+//
+//
+func reviewExportCmd() *cobra.Command {
+	options := reviewOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export explicit role grants as CSV",
+		Long: `Export every explicit role grant as CSV with user, role, granted by and the last
+connection of the user from connection history. Cells starting with =, +, - or @
+are prefixed with ' so that spreadsheets do not evaluate them as formulas.`,
+		Example: `
+	privx-cli review export [access flags] --out review.csv
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return reviewExport(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.out, "out", "", "output file (default stdout)")
+
+	return cmd
+}
+
+//
+//
+func reviewApplyCmd() *cobra.Command {
+	options := reviewOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Revoke role grants marked in review CSV",
+		Long: `Revoke role grants marked for revoke in review CSV and print summary of the
+decisions. Summary carries SHA-256 digest of the input file, SHA-256 digest of
+the summary and HMAC-SHA256 signature when signing key file is given. Digest and
+signature are computed over compact JSON of the summary without those fields.
+Roles of one user are revoked one at a time, --workers users in parallel.`,
+		Example: `
+	privx-cli review apply [access flags] --dry-run review.csv
+	privx-cli review apply [access flags] --signing-key review.key --out summary.json review.csv
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return reviewApply(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.out, "out", "", "write summary to file (default stdout)")
+	flags.StringVar(&options.signingKey, "signing-key", "", "file of the key signing the summary")
+	flags.IntVar(&options.workers, "workers", 4, "number of concurrent requests")
+	flags.BoolVar(&options.dryRun, "dry-run", false, "show the decisions without revoking roles")
+
+	return cmd
+}
+
+func reviewExport(options reviewOptions) error {
+	users, err := searchAllUsers(rolestore.UserSearchObject{})
+	if err != nil {
+		return err
+	}
+
+	last, err := lastConnections(func(conn connectionmanager.Connection) string { return conn.User.ID })
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].Principal < users[j].Principal })
+
+	var buffer bytes.Buffer
+	w := csv.NewWriter(&buffer)
+	w.Write(reviewColumns)
+
+	for _, user := range users {
+		connected := ""
+		if at, ok := last[user.ID]; ok {
+			connected = at.Format(time.RFC3339)
+		}
+
+		for _, role := range user.Roles {
+			if !role.Explicit {
+				continue
+			}
+			w.Write(csvCells([]string{user.ID, user.Principal, user.FullName, role.ID, role.Name,
+				role.GrantedBy, connected, ""}))
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	return writeExport(options.out, buffer.Bytes())
+}
+
+func reviewApply(options reviewOptions, args []string) error {
+	input, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	decisions, err := readReviewDecisions(input)
+	if err != nil {
+		return err
+	}
+
+	if !options.dryRun {
+		api := rolestore.New(curl())
+
+		// revoke updates the role list of the user, so revokes of one user
+		// are applied in order and only different users run in parallel
+		users := groupBy(len(decisions), func(i int) string { return decisions[i].UserID })
+
+		parallel(len(users), options.workers, func(n int) {
+			for _, i := range users[n] {
+				decision := &decisions[i]
+				if decision.Action != "revoke" {
+					continue
+				}
+
+				if err := api.RevokeUserRole(decision.UserID, decision.RoleID); err != nil {
+					decision.Action, decision.Error = "error", err.Error()
+				} else {
+					decision.Action = "revoked"
+				}
+			}
+		})
+	}
+
+	digest := sha256.Sum256(input)
+	summary := reviewSummary{
+		Input:       args[0],
+		InputSHA256: hex.EncodeToString(digest[:]),
+		AppliedBy:   firstOf(access, os.Getenv("PRIVX_API_ACCESS_KEY")),
+		AppliedAt:   time.Now().UTC().Format(time.RFC3339),
+		DryRun:      options.dryRun,
+		Counts:      map[string]int{},
+		Decisions:   decisions,
+	}
+
+	for _, decision := range decisions {
+		summary.Counts[decision.Action]++
+	}
+
+	if err := signReviewSummary(&summary, options.signingKey); err != nil {
+		return err
+	}
+
+	encoded, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeExport(options.out, append(encoded, '\n')); err != nil {
+		return err
+	}
+
+	if summary.Counts["error"] > 0 {
+		return fmt.Errorf("%d review decisions failed", summary.Counts["error"])
+	}
+
+	return nil
+}
+
+func readReviewDecisions(input []byte) ([]reviewDecision, error) {
+	file, err := readCSVTable(bytes.NewReader(input))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := file.require("user_id", "role_id", "decision"); err != nil {
+		return nil, err
+	}
+
+	decisions := []reviewDecision{}
+	for _, record := range file.records {
+		decision := reviewDecision{
+			Row:    record.row,
+			UserID: record.cells["user_id"],
+			User:   record.cells["user"],
+			RoleID: record.cells["role_id"],
+			Role:   record.cells["role"],
+		}
+
+		switch action := strings.ToLower(record.cells["decision"]); action {
+		case "keep", "revoke":
+			decision.Action = action
+		case "":
+			decision.Action = "undecided"
+		default:
+			decision.Action, decision.Error = "error", "unknown decision "+action
+		}
+
+		if decision.UserID == "" || decision.RoleID == "" {
+			decision.Action, decision.Error = "error", "user_id and role_id are required"
+		}
+
+		decisions = append(decisions, decision)
+	}
+
+	return decisions, nil
+}
+
+// signReviewSummary sets digest of the summary and signs it with the key
+// read from file, if given
+func signReviewSummary(summary *reviewSummary, keyFile string) error {
+	content, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256(content)
+	summary.Digest = "sha256:" + hex.EncodeToString(digest[:])
+
+	if keyFile == "" {
+		return nil
+	}
+
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha256.New, bytes.TrimSpace(key))
+	mac.Write(content)
+	summary.Signature = "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+
+	return nil
+}
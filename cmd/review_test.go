@@ -0,0 +1,52 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadReviewDecisions(t *testing.T) {
+	input := "User_ID, user ,role_id,role,decision\n" +
+		"u1,alice,r1,admins,keep\n" +
+		"u1,alice,r2,ops, REVOKE \n" +
+		"u2,bob,r1,admins,\n" +
+		"u2,bob,r2,ops,maybe\n" +
+		",carol,r1,admins,revoke\n" +
+		"u3,dave\n"
+
+	decisions, err := readReviewDecisions([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []reviewDecision{
+		{Row: 2, UserID: "u1", User: "alice", RoleID: "r1", Role: "admins", Action: "keep"},
+		{Row: 3, UserID: "u1", User: "alice", RoleID: "r2", Role: "ops", Action: "revoke"},
+		{Row: 4, UserID: "u2", User: "bob", RoleID: "r1", Role: "admins", Action: "undecided"},
+		{Row: 5, UserID: "u2", User: "bob", RoleID: "r2", Role: "ops", Action: "error", Error: "unknown decision maybe"},
+		{Row: 6, User: "carol", RoleID: "r1", Role: "admins", Action: "error", Error: "user_id and role_id are required"},
+		{Row: 7, UserID: "u3", User: "dave", Action: "error", Error: "user_id and role_id are required"},
+	}
+	if !reflect.DeepEqual(decisions, expected) {
+		t.Errorf("decisions\n%+v\nexpected\n%+v", decisions, expected)
+	}
+}
+
+func TestReadReviewDecisionsErrors(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"user_id,role_id\nu1,r1\n",
+		"user_id,decision\nu1,keep\n",
+		"user_id,role_id,decision\n\"u1,r1,keep\n",
+	} {
+		if _, err := readReviewDecisions([]byte(input)); err == nil {
+			t.Errorf("readReviewDecisions(%q), expected error", input)
+		}
+	}
+}
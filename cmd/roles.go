@@ -49,6 +49,9 @@ func roleListCmd() *cobra.Command {
 	cmd.AddCommand(rolesMemberListCmd())
 	cmd.AddCommand(roleResolveCmd())
 	cmd.AddCommand(awsTokenShowCmd())
+	cmd.AddCommand(roleCloneCmd())
+	cmd.AddCommand(roleRenderCmd())
+	cmd.AddCommand(roleSimulateCmd())
 
 	return cmd
 }
@@ -0,0 +1,244 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/spf13/cobra"
+)
+
+type roleSimulateOptions struct {
+	roleID string
+	format string
+}
+
+// sourceRule is a node of role source rules, either GROUP combining
+// nested rules or RULE matching users
+type sourceRule struct {
+	Type         string       `json:"type"`
+	Match        string       `json:"match"`
+	Source       string       `json:"source"`
+	SearchString string       `json:"search_string"`
+	Group        string       `json:"group"`
+	Rules        []sourceRule `json:"rules"`
+}
+
+type roleMembershipChange struct {
+	Change      string `json:"change"`
+	ID          string `json:"id"`
+	Principal   string `json:"principal"`
+	FullName    string `json:"full_name"`
+	Email       string `json:"email"`
+	SourceID    string `json:"source_id"`
+	Approximate bool   `json:"approximate"`
+}
+
+// user attributes holding group memberships
+var userGroupAttributes = []string{"groups", "member_of", "ldap_groups"}
+
+//
+//
+func roleSimulateCmd() *cobra.Command {
+	options := roleSimulateOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "simulate",
+		Short: "Show how source rule change would affect role members",
+		Long: `Evaluate source rules of proposed role definition against users of the user
+store and show users who would gain or lose the role compared to its current
+members. Explicitly granted members keep the role. Rules are evaluated locally:
+source must equal user source ID and group must be one of the user groups.
+PrivX matches search strings against source specific attributes, which are
+approximated by any user attribute containing the search string (case
+insensitive). Changes depending on a search string are marked approximate.`,
+		Example: `
+	privx-cli roles simulate [access flags] --id <ROLE-ID> JSON-FILE
+	privx-cli roles simulate [access flags] --id <ROLE-ID> --format table JSON-FILE
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return roleSimulate(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.roleID, "id", "", "role ID")
+	flags.StringVar(&options.format, "format", "json", "output format, table, csv or json")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+func roleSimulate(options roleSimulateOptions, args []string) error {
+	proposed := struct {
+		SourceRules *sourceRule `json:"source_rules"`
+	}{}
+
+	err := decodeJSON(args[0], &proposed)
+	if err != nil {
+		return err
+	}
+	if proposed.SourceRules == nil {
+		return errors.New("role definition has no source rules")
+	}
+
+	members, err := rolestore.New(curl()).GetRoleMembers(options.roleID)
+	if err != nil {
+		return err
+	}
+
+	current := map[string]bool{}
+	explicit := map[string]bool{}
+	for _, member := range members {
+		current[member.ID] = true
+		for _, role := range member.Roles {
+			if role.ID == options.roleID && role.Explicit {
+				explicit[member.ID] = true
+			}
+		}
+	}
+
+	users, err := searchAllUsers(rolestore.UserSearchObject{})
+	if err != nil {
+		return err
+	}
+
+	attrs := []map[string]interface{}{}
+	if err := reshape(users, &attrs); err != nil {
+		return err
+	}
+
+	changes := []roleMembershipChange{}
+	for _, user := range attrs {
+		id := attrString(user, "id")
+		match := explicit[id] || proposed.SourceRules.matches(user)
+
+		change := ""
+		switch {
+		case match && !current[id]:
+			change = "gain"
+		case !match && current[id]:
+			change = "lose"
+		default:
+			continue
+		}
+
+		changes = append(changes, roleMembershipChange{
+			Change:      change,
+			ID:          id,
+			Principal:   attrString(user, "principal"),
+			FullName:    attrString(user, "full_name"),
+			Email:       attrString(user, "email"),
+			SourceID:    attrString(user, "source_id"),
+			Approximate: !explicit[id] && proposed.SourceRules.approximate(user),
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Change != changes[j].Change {
+			return changes[i].Change < changes[j].Change
+		}
+		return changes[i].Principal < changes[j].Principal
+	})
+
+	return report(options.format, changes, func() *table {
+		t := newTable("CHANGE", "ID", "PRINCIPAL", "FULL NAME", "EMAIL", "SOURCE", "APPROXIMATE")
+		for _, c := range changes {
+			t.add(c.Change, c.ID, c.Principal, c.FullName, c.Email, c.SourceID, fmt.Sprint(c.Approximate))
+		}
+		return t
+	})
+}
+
+// matches evaluates the rule against user attributes
+func (rule sourceRule) matches(user map[string]interface{}) bool {
+	return rule.evaluate(user, func(text string) bool {
+		return attrContains(user, strings.ToLower(text))
+	})
+}
+
+// approximate tells whether the result for the user depends on search
+// strings, which are only approximated locally
+func (rule sourceRule) approximate(user map[string]interface{}) bool {
+	always := func(string) bool { return true }
+	never := func(string) bool { return false }
+
+	return rule.evaluate(user, always) != rule.evaluate(user, never)
+}
+
+// evaluate evaluates the rule, search tells whether search string matches
+func (rule sourceRule) evaluate(user map[string]interface{}, search func(string) bool) bool {
+	if strings.EqualFold(rule.Type, "GROUP") || len(rule.Rules) > 0 {
+		all := !strings.EqualFold(rule.Match, "ANY")
+		for _, sub := range rule.Rules {
+			if sub.evaluate(user, search) != all {
+				return !all
+			}
+		}
+		return all && len(rule.Rules) > 0
+	}
+
+	if rule.Source != "" && attrString(user, "source_id") != rule.Source {
+		return false
+	}
+
+	if rule.Group != "" && !userInGroup(user, rule.Group) {
+		return false
+	}
+
+	if rule.SearchString != "" && !search(rule.SearchString) {
+		return false
+	}
+
+	return rule.Source != "" || rule.Group != "" || rule.SearchString != ""
+}
+
+func userInGroup(user map[string]interface{}, group string) bool {
+	for _, key := range userGroupAttributes {
+		groups, _ := user[key].([]interface{})
+		for _, g := range groups {
+			if strings.EqualFold(fmt.Sprint(g), group) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// attrContains tells whether any attribute value contains the lower case text
+func attrContains(value interface{}, text string) bool {
+	switch v := value.(type) {
+	case string:
+		return strings.Contains(strings.ToLower(v), text)
+	case []interface{}:
+		for _, item := range v {
+			if attrContains(item, text) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			if key != "roles" && attrContains(item, text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func attrString(user map[string]interface{}, key string) string {
+	if value, ok := user[key].(string); ok {
+		return value
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import "testing"
+
+func TestSourceRuleMatches(t *testing.T) {
+	user := map[string]interface{}{
+		"id":        "u1",
+		"principal": "alice",
+		"email":     "alice@example.com",
+		"source_id": "ldap",
+		"groups":    []interface{}{"Admins", "dev"},
+	}
+
+	for _, test := range []struct {
+		name  string
+		rule  sourceRule
+		match bool
+	}{
+		{"source", sourceRule{Source: "ldap"}, true},
+		{"other source", sourceRule{Source: "local"}, false},
+		{"group", sourceRule{Group: "admins"}, true},
+		{"missing group", sourceRule{Group: "ops"}, false},
+		{"search string", sourceRule{SearchString: "ALICE@"}, true},
+		{"search string not found", sourceRule{SearchString: "bob"}, false},
+		{"source and group", sourceRule{Source: "ldap", Group: "ops"}, false},
+		{"empty rule", sourceRule{}, false},
+		{"empty group", sourceRule{Type: "GROUP", Match: "ALL"}, false},
+		{"all", sourceRule{Type: "GROUP", Match: "ALL", Rules: []sourceRule{{Source: "ldap"}, {Group: "dev"}}}, true},
+		{"all failing", sourceRule{Type: "GROUP", Match: "ALL", Rules: []sourceRule{{Source: "ldap"}, {Group: "ops"}}}, false},
+		{"any", sourceRule{Type: "GROUP", Match: "ANY", Rules: []sourceRule{{Source: "local"}, {Group: "dev"}}}, true},
+		{"any failing", sourceRule{Type: "GROUP", Match: "ANY", Rules: []sourceRule{{Source: "local"}, {Group: "ops"}}}, false},
+		{"nested", sourceRule{Type: "GROUP", Match: "ALL", Rules: []sourceRule{
+			{Source: "ldap"},
+			{Type: "GROUP", Match: "ANY", Rules: []sourceRule{{Group: "ops"}, {Group: "admins"}}},
+		}}, true},
+	} {
+		if match := test.rule.matches(user); match != test.match {
+			t.Errorf("%s: match %v, expected %v", test.name, match, test.match)
+		}
+	}
+}
+
+func TestSourceRuleApproximate(t *testing.T) {
+	user := map[string]interface{}{"id": "u1", "source_id": "ldap", "groups": []interface{}{"dev"}}
+
+	for _, test := range []struct {
+		name        string
+		rule        sourceRule
+		approximate bool
+	}{
+		{"no search string", sourceRule{Source: "ldap"}, false},
+		{"search string", sourceRule{Source: "ldap", SearchString: "alice"}, true},
+		{"search string of other source", sourceRule{Source: "local", SearchString: "alice"}, false},
+		{"decided by other rule", sourceRule{Type: "GROUP", Match: "ANY", Rules: []sourceRule{
+			{Group: "dev"},
+			{SearchString: "alice"},
+		}}, false},
+		{"decided by search string", sourceRule{Type: "GROUP", Match: "ANY", Rules: []sourceRule{
+			{Group: "ops"},
+			{SearchString: "alice"},
+		}}, true},
+	} {
+		if approximate := test.rule.approximate(user); approximate != test.approximate {
+			t.Errorf("%s: approximate %v, expected %v", test.name, approximate, test.approximate)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/spf13/cobra"
+)
+
+type roleTemplateOptions struct {
+	roleID   string
+	roleName string
+	vars     []string
+	create   bool
+}
+
+//
+//
+func roleCloneCmd() *cobra.Command {
+	options := roleTemplateOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "clone",
+		Short: "Clone role under new name",
+		Long: `Clone role under new name. The new role has the same permissions, principals,
+rules, access group and source rules as the original role, but no members.`,
+		Example: `
+	privx-cli roles clone [access flags] --id <ROLE-ID> --name <ROLE-NAME>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return roleClone(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.roleID, "id", "", "role ID")
+	flags.StringVar(&options.roleName, "name", "", "name of the new role")
+	cmd.MarkFlagRequired("id")
+	cmd.MarkFlagRequired("name")
+
+	return cmd
+}
+
+func roleClone(options roleTemplateOptions) error {
+	api := rolestore.New(curl())
+
+	role, err := api.Role(options.roleID)
+	if err != nil {
+		return err
+	}
+
+	clone := *role
+	clone.ID = ""
+	clone.Name = options.roleName
+	clone.System = false
+	clone.Explicit, clone.Implicit = false, false
+	clone.MemberCount = 0
+
+	id, err := api.CreateRole(clone)
+	if err != nil {
+		return err
+	}
+
+	return stdout(id)
+}
+
+//
+//
+func roleRenderCmd() *cobra.Command {
+	options := roleTemplateOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "render",
+		Short: "Render role definition from template",
+		Long: `Render role definition from Go text template. Variables given with
+--var KEY=VALUE are referred as {{.KEY}} in the template, rendering fails if
+template refers to undefined variable. {{json .KEY}} renders the value as quoted
+and escaped JSON string, e.g. "name": {{json .team}}. Rendered role is printed,
+or created with --create. Attributes unknown to the role definition are rejected.`,
+		Example: `
+	privx-cli roles render [access flags] --var team=payments TEMPLATE-FILE
+	privx-cli roles render [access flags] --var team=payments --var group=<ACCESS-GROUP-ID> --create TEMPLATE-FILE
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return roleRender(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringArrayVar(&options.vars, "var", []string{}, "template variable as key=value")
+	flags.BoolVar(&options.create, "create", false, "create the rendered role")
+
+	return cmd
+}
+
+func roleRender(options roleTemplateOptions, args []string) error {
+	vars, err := keyValues(options.vars, "template variable")
+	if err != nil {
+		return err
+	}
+
+	text, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New(filepath.Base(args[0])).
+		Funcs(template.FuncMap{"json": jsonString}).
+		Option("missingkey=error").
+		Parse(string(text))
+	if err != nil {
+		return err
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, vars); err != nil {
+		return err
+	}
+
+	var role rolestore.Role
+	decoder := json.NewDecoder(&rendered)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&role); err != nil {
+		return errors.New("rendered role is not valid: " + err.Error())
+	}
+
+	if !options.create {
+		return stdout(role)
+	}
+
+	id, err := rolestore.New(curl()).CreateRole(role)
+	if err != nil {
+		return err
+	}
+
+	return stdout(id)
+}
+
+// jsonString encodes the template value as JSON string
+func jsonString(value string) (string, error) {
+	encoded, err := json.Marshal(value)
+	return string(encoded), err
+}
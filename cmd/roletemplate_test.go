@@ -0,0 +1,28 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import "testing"
+
+func TestJSONString(t *testing.T) {
+	for _, test := range []struct {
+		value, expected string
+	}{
+		{"payments", `"payments"`},
+		{`team "eu"`, `"team \"eu\""`},
+		{"a\\b\nc", `"a\\b\nc"`},
+		{"", `""`},
+	} {
+		encoded, err := jsonString(test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if encoded != test.expected {
+			t.Errorf("jsonString(%q) = %s, expected %s", test.value, encoded, test.expected)
+		}
+	}
+}
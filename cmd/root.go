@@ -8,6 +8,7 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 
 	"github.com/SSHcom/privx-sdk-go/oauth"
@@ -15,15 +16,38 @@ import (
 	"github.com/spf13/cobra"
 )
 
-// Execute is entry point to application
+// Execute is entry point to application, commands reporting their result
+// through exit code end the process with that code
 func Execute() error {
-	return rootCmd.Execute()
+	err := rootCmd.Execute()
+
+	var exit *exitError
+	if errors.As(err, &exit) {
+		os.Exit(exit.code)
+	}
+
+	return err
+}
+
+// exitError carries exit code of the command, commands executed by run see
+// it as an ordinary error
+type exitError struct {
+	code    int
+	message string
+}
+
+func (err *exitError) Error() string {
+	return err.message
 }
 
 var (
 	config string
 	access string
 	secret string
+
+	// connector is shared by all commands executed within the process,
+	// so that a script run authenticates only once.
+	connector restapi.Connector
 )
 
 func init() {
@@ -76,11 +100,15 @@ func auth() restapi.Authorizer {
 }
 
 func curl() restapi.Connector {
-	return restapi.New(
-		restapi.Auth(auth()),
-		restapi.UseConfigFile(config),
-		restapi.UseEnvironment(),
-	)
+	if connector == nil {
+		connector = restapi.New(
+			restapi.Auth(auth()),
+			restapi.UseConfigFile(config),
+			restapi.UseEnvironment(),
+		)
+	}
+
+	return connector
 }
 
 func stdout(data interface{}) error {
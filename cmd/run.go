@@ -0,0 +1,312 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+type runOptions struct {
+	stopOnError bool
+}
+
+type scriptFailure struct {
+	line    int
+	command string
+	err     error
+}
+
+// $NAME := command ... | .path
+var scriptAssignment = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)\s*:=\s*(.+)$`)
+
+func init() {
+	rootCmd.AddCommand(runCmd())
+}
+
+//
+//
+func runCmd() *cobra.Command {
+	options := runOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Execute a script of privx-cli commands",
+		Long: `Execute a script of privx-cli commands line by line within a single session.
+Each line is a privx-cli command without the program name. Empty lines and lines
+starting with # are ignored. The output of a command is captured into a variable
+with $NAME := command, optionally followed by | .path to pick a field from the JSON
+output. JSON strings are stored without quotes. Variables are referenced as $NAME
+or ${NAME} in the following lines.`,
+		Example: `
+	privx-cli run [access flags] SCRIPT-FILE
+	privx-cli run [access flags] --stop-on-error SCRIPT-FILE
+
+	# script example
+	$ROLE_ID := roles create role.json
+	users roles --id <USER-ID> --grant $ROLE_ID
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runScript(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&options.stopOnError, "stop-on-error", false, "stop the script at the first failing command")
+
+	return cmd
+}
+
+func runScript(options runOptions, args []string) error {
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// failures are reported in the summary instead of by each command
+	rootCmd.SilenceErrors = true
+	defer func() { rootCmd.SilenceErrors = false }()
+
+	vars := map[string]string{}
+	lookup := func(name string) string {
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return os.Getenv(name)
+	}
+
+	executed, skipped := 0, 0
+	failures := []scriptFailure{}
+	scanner := bufio.NewScanner(file)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if options.stopOnError && len(failures) > 0 {
+			skipped++
+			continue
+		}
+
+		executed++
+		if err := runScriptLine(line, vars, lookup); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: line %d: %v\n", n, err)
+			failures = append(failures, scriptFailure{line: n, command: line, err: err})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "\n%d commands executed: %d succeeded, %d failed, %d skipped\n",
+		executed, executed-len(failures), len(failures), skipped)
+	for _, failure := range failures {
+		fmt.Fprintf(os.Stderr, "  line %d: %s: %v\n", failure.line, failure.command, failure.err)
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%d of %d commands failed", len(failures), executed)
+	}
+
+	return nil
+}
+
+func runScriptLine(line string, vars map[string]string, lookup func(string) string) error {
+	name, path := "", ""
+	if match := scriptAssignment.FindStringSubmatch(line); match != nil {
+		name, line = match[1], match[2]
+
+		if at := strings.LastIndex(line, "|"); at != -1 {
+			if field := strings.TrimSpace(line[at+1:]); strings.HasPrefix(field, ".") {
+				line, path = strings.TrimSpace(line[:at]), field
+			}
+		}
+	}
+
+	args, err := splitCommandLine(os.Expand(line, lookup))
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 && args[0] == rootCmd.Name() {
+		args = args[1:]
+	}
+
+	if len(args) == 0 {
+		return errors.New("missing command")
+	}
+
+	if args[0] == "run" {
+		return errors.New("nested scripts are not supported")
+	}
+
+	if name == "" {
+		return executeCommand(args)
+	}
+
+	output, err := captureCommand(args)
+	if err != nil {
+		return err
+	}
+
+	value, err := scriptValue(output, path)
+	if err != nil {
+		return err
+	}
+
+	vars[name] = value
+	return nil
+}
+
+// executeCommand runs the command line with the shared root command. Flags
+// keep their values between executions, so they are reset beforehand.
+func executeCommand(args []string) error {
+	resetFlags(rootCmd)
+	rootCmd.SetArgs(args)
+
+	_, err := rootCmd.ExecuteC()
+	return err
+}
+
+// captureCommand runs the command line and returns whatever it writes to stdout
+func captureCommand(args []string) ([]byte, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	output := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- data
+	}()
+
+	saved := os.Stdout
+	os.Stdout = writer
+	err = executeCommand(args)
+	os.Stdout = saved
+	writer.Close()
+
+	return <-output, err
+}
+
+// scriptValue picks the path from JSON output, whole output is taken without
+// a path and JSON strings are unquoted
+func scriptValue(output []byte, path string) (string, error) {
+	var object interface{}
+	if err := json.Unmarshal(output, &object); err != nil {
+		if path == "" {
+			return strings.TrimSpace(string(output)), nil
+		}
+		return "", fmt.Errorf("command output is not JSON: %w", err)
+	}
+
+	if path == "" {
+		if text, ok := object.(string); ok {
+			return text, nil
+		}
+		return strings.TrimSpace(string(output)), nil
+	}
+
+	value, err := lookupPath(object, path)
+	if err != nil {
+		return "", err
+	}
+
+	if text, ok := value.(string); ok {
+		return text, nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(encoded), nil
+}
+
+func resetFlags(cmd *cobra.Command) {
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		// access flags are given to the run command itself
+		if rootCmd.PersistentFlags().Lookup(flag.Name) != nil {
+			return
+		}
+
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			slice.Replace([]string{})
+		} else {
+			flag.Value.Set(flag.DefValue)
+		}
+		flag.Changed = false
+	})
+
+	for _, sub := range cmd.Commands() {
+		resetFlags(sub)
+	}
+}
+
+// splitCommandLine splits the line into arguments, honouring quotes and
+// backslash escapes the same way a shell does for simple command lines.
+func splitCommandLine(line string) ([]string, error) {
+	args := []string{}
+	current := strings.Builder{}
+	inArg, escaped := false, false
+	var quote rune
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape: %s", line)
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
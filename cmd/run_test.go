@@ -0,0 +1,84 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScriptValue(t *testing.T) {
+	for _, test := range []struct {
+		name, output, path, expected string
+	}{
+		{"created ID", "\"8d5e1a2c\"\n", "", "8d5e1a2c"},
+		{"created ID with dot path", "\"8d5e1a2c\"\n", ".", "8d5e1a2c"},
+		{"field", `{"id": "r1", "name": "admins"}`, ".id", "r1"},
+		{"nested field", `{"items": [{"id": "r1"}, {"id": "r2"}]}`, ".items[1].id", "r2"},
+		{"object field", `{"role": {"id": "r1"}}`, ".role", `{"id":"r1"}`},
+		{"number", "42\n", "", "42"},
+		{"whole object", "{\"id\": \"r1\"}\n", "", `{"id": "r1"}`},
+		{"plain text", "token\n", "", "token"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := scriptValue([]byte(test.output), test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != test.expected {
+				t.Errorf("value %q, expected %q", value, test.expected)
+			}
+		})
+	}
+}
+
+func TestScriptValueErrors(t *testing.T) {
+	for _, test := range []struct {
+		output, path string
+	}{
+		{"token", ".id"},
+		{`{"id": "r1"}`, ".name"},
+		{`{"items": []}`, ".items[0]"},
+	} {
+		if value, err := scriptValue([]byte(test.output), test.path); err == nil {
+			t.Errorf("scriptValue(%q, %q) = %q, expected error", test.output, test.path, value)
+		}
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	for _, test := range []struct {
+		line     string
+		expected []string
+	}{
+		{"", []string{}},
+		{"roles", []string{"roles"}},
+		{"  users   show\t--id u1 ", []string{"users", "show", "--id", "u1"}},
+		{`hosts create "my host.json"`, []string{"hosts", "create", "my host.json"}},
+		{`secrets create 'it''s'`, []string{"secrets", "create", "its"}},
+		{`echo 'a\b' "c\"d"`, []string{"echo", `a\b`, `c"d`}},
+		{`file\ name ""`, []string{"file name", ""}},
+		{`--name=a"b c"d`, []string{"--name=ab cd"}},
+	} {
+		args, err := splitCommandLine(test.line)
+		if err != nil {
+			t.Errorf("splitCommandLine(%q): %v", test.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(args, test.expected) {
+			t.Errorf("splitCommandLine(%q) = %q, expected %q", test.line, args, test.expected)
+		}
+	}
+}
+
+func TestSplitCommandLineErrors(t *testing.T) {
+	for _, line := range []string{`"unterminated`, `'unterminated`, `trailing\`} {
+		if args, err := splitCommandLine(line); err == nil {
+			t.Errorf("splitCommandLine(%q) = %q, expected error", line, args)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/SSHcom/privx-sdk-go/api/vault"
+	"github.com/spf13/cobra"
+)
+
+type secretExecOptions struct {
+	mapping []string
+}
+
+// secretCache fetches each secret once, user secrets are identified by owner
+type secretCache struct {
+	api     *vault.Vault
+	secrets map[string]*vault.Secret
+}
+
+//
+//
+func secretExecCmd() *cobra.Command {
+	options := secretExecOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "exec",
+		Short: "Run command with secrets in environment",
+		Long: `Run command with fields of secrets in its environment. Secrets are mapped to
+environment variables with --map VAR=SECRET.FIELD, field is a path to the secret
+data, e.g. db.password or accounts[0].password. Secrets are given only to the
+command and never written to disk. Signals are forwarded to the command and the
+exit code of the command is returned.`,
+		Example: `
+	privx-cli secrets exec [access flags] --map DB_PASS=db-prod.password --map API_KEY=ci.token -- ./deploy.sh
+		`,
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return secretExec(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringArrayVar(&options.mapping, "map", []string{}, "environment variable from secret field, VAR=SECRET.FIELD")
+	flags.SetInterspersed(false)
+	cmd.MarkFlagRequired("map")
+
+	return cmd
+}
+
+func secretExec(options secretExecOptions, args []string) error {
+	mapping, err := keyValues(options.mapping, "mapping")
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range mapping {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cache := newSecretCache()
+	env := os.Environ()
+
+	for _, name := range names {
+		secretName, field, ok := strings.Cut(mapping[name], ".")
+		if !ok || secretName == "" || field == "" {
+			return fmt.Errorf("mapping must be VAR=SECRET.FIELD: %s=%s", name, mapping[name])
+		}
+
+		value, err := cache.field(vault.SecretID{Name: secretName}, field)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		env = append(env, name+"="+string(value))
+	}
+
+	child := exec.Command(args[0], args[1:]...)
+	child.Env = env
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	defer signal.Stop(signals)
+
+	if err := child.Start(); err != nil {
+		return err
+	}
+
+	go func() {
+		for sig := range signals {
+			child.Process.Signal(sig)
+		}
+	}()
+
+	err = child.Wait()
+
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		if status, ok := exit.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			os.Exit(128 + int(status.Signal()))
+		}
+		os.Exit(exit.ExitCode())
+	}
+
+	return err
+}
+
+func newSecretCache() *secretCache {
+	return &secretCache{
+		api:     vault.New(curl()),
+		secrets: map[string]*vault.Secret{},
+	}
+}
+
+// field reads the field of secret, or user secret if owner is given
+func (cache *secretCache) field(id vault.SecretID, field string) ([]byte, error) {
+	key := id.OwnerID + "/" + id.Name
+
+	secret, ok := cache.secrets[key]
+	if !ok {
+		var err error
+		if id.OwnerID == "" {
+			secret, err = cache.api.Secret(id.Name)
+		} else {
+			secret, err = cache.api.UserSecret(id)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("secret %s: %w", id.Name, err)
+		}
+		cache.secrets[key] = secret
+	}
+
+	return secretField(secret, field)
+}
@@ -0,0 +1,227 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/vault"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+type secretGetOptions struct {
+	secretName string
+	ownerID    string
+	field      string
+	base64     bool
+	toFile     string
+	clipboard  bool
+	clearAfter time.Duration
+}
+
+//
+//
+func secretGetCmd() *cobra.Command {
+	options := secretGetOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "get",
+		Short: "Get a field of secret as raw value",
+		Long: `Get a field of secret as raw value without trailing newline. Field is a path to
+the secret data, nested fields are separated by dots and list items are given by
+index, e.g. accounts[0].password. Strings are printed as is, other values as JSON.`,
+		Example: `
+	privx-cli secrets get [access flags] --name <SECRET-NAME> --field password
+	privx-cli secrets get [access flags] --name <SECRET-NAME> --field db.password --to-file db.pass
+	privx-cli secrets get [access flags] --name <SECRET-NAME> --field password --clipboard --clear-after 20s
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return secretGet(options)
+		},
+	}
+
+	options.flags(cmd.Flags())
+	cmd.MarkFlagRequired("name")
+	cmd.MarkFlagRequired("field")
+
+	return cmd
+}
+
+//
+//
+func userSecretGetCmd() *cobra.Command {
+	options := secretGetOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "get",
+		Short: "Get a field of user secret as raw value",
+		Long: `Get a field of user secret as raw value without trailing newline. Field is a
+path to the secret data, nested fields are separated by dots and list items are
+given by index, e.g. accounts[0].password. Strings are printed as is, other
+values as JSON.`,
+		Example: `
+	privx-cli user-secrets get [access flags] --owner-id <OWNER-ID> --name <SECRET-NAME> --field password
+	privx-cli user-secrets get [access flags] --owner-id <OWNER-ID> --name <SECRET-NAME> --field key --base64
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return userSecretGet(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	options.flags(flags)
+	flags.StringVar(&options.ownerID, "owner-id", "", "secret's owner ID")
+	cmd.MarkFlagRequired("owner-id")
+	cmd.MarkFlagRequired("name")
+	cmd.MarkFlagRequired("field")
+
+	return cmd
+}
+
+func (options *secretGetOptions) flags(flags *pflag.FlagSet) {
+	flags.StringVar(&options.secretName, "name", "", "secret name")
+	flags.StringVar(&options.field, "field", "", "path to the field of secret data")
+	flags.BoolVar(&options.base64, "base64", false, "encode the value with base64")
+	flags.StringVar(&options.toFile, "to-file", "", "write the value to file readable only by the owner")
+	flags.BoolVar(&options.clipboard, "clipboard", false, "copy the value to clipboard")
+	flags.DurationVar(&options.clearAfter, "clear-after", 45*time.Second, "clear the clipboard after the duration, 0 keeps the value")
+}
+
+func secretGet(options secretGetOptions) error {
+	secret, err := vault.New(curl()).Secret(options.secretName)
+	if err != nil {
+		return err
+	}
+
+	return outputSecretField(secret, options)
+}
+
+func userSecretGet(options secretGetOptions) error {
+	secretID := vault.SecretID{OwnerID: options.ownerID, Name: options.secretName}
+
+	secret, err := vault.New(curl()).UserSecret(secretID)
+	if err != nil {
+		return err
+	}
+
+	return outputSecretField(secret, options)
+}
+
+func outputSecretField(secret *vault.Secret, options secretGetOptions) error {
+	if options.toFile != "" && options.clipboard {
+		return fmt.Errorf("use either --to-file or --clipboard")
+	}
+
+	value, err := secretField(secret, options.field)
+	if err != nil {
+		return err
+	}
+
+	if options.base64 {
+		value = []byte(base64.StdEncoding.EncodeToString(value))
+	}
+
+	switch {
+	case options.toFile != "":
+		return writeSecretFile(options.toFile, value)
+	case options.clipboard:
+		return copyToClipboard(value, options.clearAfter)
+	default:
+		_, err := os.Stdout.Write(value)
+		return err
+	}
+}
+
+// secretField reads the field from the secret data, strings are returned as
+// is and other values as JSON
+func secretField(secret *vault.Secret, field string) ([]byte, error) {
+	value, err := lookupPath(secret.Data, strings.TrimPrefix(field, "."))
+	if err != nil {
+		return nil, err
+	}
+
+	if text, ok := value.(string); ok {
+		return []byte(text), nil
+	}
+
+	return json.Marshal(value)
+}
+
+// clipboardCommand returns the command writing standard input to clipboard
+func clipboardCommand() ([]string, error) {
+	candidates := [][]string{
+		{"wl-copy"},
+		{"xclip", "-selection", "clipboard"},
+		{"xsel", "--clipboard", "--input"},
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = [][]string{{"pbcopy"}}
+	case "windows":
+		candidates = [][]string{{"clip.exe"}}
+	}
+
+	for _, command := range candidates {
+		if _, err := exec.LookPath(command[0]); err == nil {
+			return command, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no clipboard command found, tried %s", candidates)
+}
+
+func writeClipboard(command []string, value []byte) error {
+	clip := exec.Command(command[0], command[1:]...)
+	clip.Stdin = bytes.NewReader(value)
+	clip.Stderr = os.Stderr
+
+	return clip.Run()
+}
+
+// copyToClipboard copies the value to clipboard and clears it after the
+// duration, or when interrupted
+func copyToClipboard(value []byte, clearAfter time.Duration) error {
+	command, err := clipboardCommand()
+	if err != nil {
+		return err
+	}
+
+	if err := writeClipboard(command, value); err != nil {
+		return err
+	}
+
+	if clearAfter <= 0 {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "copied to clipboard, clearing in %s\n", clearAfter)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	select {
+	case <-time.After(clearAfter):
+	case <-interrupt:
+	}
+
+	return writeClipboard(command, []byte{})
+}
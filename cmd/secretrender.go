@@ -0,0 +1,178 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/SSHcom/privx-sdk-go/api/vault"
+	"github.com/spf13/cobra"
+)
+
+type secretRenderOptions struct {
+	out   string
+	check bool
+}
+
+// secretReference is a secret field referred by template, reported by
+// check without the value
+type secretReference struct {
+	Secret  string `json:"secret"`
+	OwnerID string `json:"owner_id,omitempty"`
+	Field   string `json:"field"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+//
+//
+func secretRenderCmd() *cobra.Command {
+	options := secretRenderOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "render",
+		Short: "Render template with secrets",
+		Long: `Render Go template with fields of secrets. Template functions are
+secret "NAME" "FIELD" for secrets and userSecret "OWNER-ID" "NAME" "FIELD" for
+user secrets, field is a path to the secret data, e.g. db.password. Output file
+is readable only by the owner. --check reports the referenced secrets which are
+missing or unreadable without rendering or revealing the values.`,
+		Example: `
+	privx-cli secrets render [access flags] app.conf.tmpl -o app.conf
+	privx-cli secrets render [access flags] --check app.conf.tmpl
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return secretRender(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&options.out, "out", "o", "", "output file (default stdout)")
+	flags.BoolVar(&options.check, "check", false, "check the referenced secrets without rendering")
+
+	return cmd
+}
+
+func secretRender(options secretRenderOptions, args []string) error {
+	text, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	if options.check {
+		return secretRenderCheck(args[0], string(text))
+	}
+
+	cache := newSecretCache()
+	funcs := template.FuncMap{
+		"secret": func(name, field string) (string, error) {
+			value, err := cache.field(vault.SecretID{Name: name}, field)
+			return string(value), err
+		},
+		"userSecret": func(owner, name, field string) (string, error) {
+			value, err := cache.field(vault.SecretID{OwnerID: owner, Name: name}, field)
+			return string(value), err
+		},
+	}
+
+	tmpl, err := template.New(filepath.Base(args[0])).Funcs(funcs).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return err
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, nil); err != nil {
+		return err
+	}
+
+	if options.out == "" {
+		_, err := os.Stdout.Write(rendered.Bytes())
+		return err
+	}
+
+	return writeSecretFile(options.out, rendered.Bytes())
+}
+
+// secretRenderCheck resolves every secret reference of the template and
+// reports the failing ones, values are discarded
+func secretRenderCheck(name, text string) error {
+	cache := newSecretCache()
+	references := []secretReference{}
+	seen := map[string]bool{}
+
+	resolve := func(id vault.SecretID, field string) string {
+		key := strings.Join([]string{id.OwnerID, id.Name, field}, "\x00")
+		if seen[key] {
+			return ""
+		}
+		seen[key] = true
+
+		reference := secretReference{Secret: id.Name, OwnerID: id.OwnerID, Field: field, Status: "ok"}
+		if _, err := cache.field(id, field); err != nil {
+			reference.Status, reference.Error = secretErrorStatus(err), err.Error()
+		}
+		references = append(references, reference)
+
+		return ""
+	}
+
+	funcs := template.FuncMap{
+		"secret": func(name, field string) string {
+			return resolve(vault.SecretID{Name: name}, field)
+		},
+		"userSecret": func(owner, name, field string) string {
+			return resolve(vault.SecretID{OwnerID: owner, Name: name}, field)
+		},
+	}
+
+	tmpl, err := template.New(filepath.Base(name)).Funcs(funcs).Parse(text)
+	if err != nil {
+		return err
+	}
+
+	var discard bytes.Buffer
+	if err := tmpl.Execute(&discard, nil); err != nil {
+		return err
+	}
+
+	if err := stdout(references); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, reference := range references {
+		if reference.Status != "ok" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d secret references are missing or unreadable", failed)
+	}
+
+	return nil
+}
+
+// secretErrorStatus classifies error of secret reference
+func secretErrorStatus(err error) string {
+	message := strings.ToLower(err.Error())
+
+	switch {
+	case strings.Contains(message, "not found in path"):
+		return "missing field"
+	case isNotFound(err):
+		return "missing"
+	default:
+		return "unreadable"
+	}
+}
@@ -0,0 +1,194 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/connectionmanager"
+	"github.com/SSHcom/privx-sdk-go/api/licensemanager"
+	"github.com/SSHcom/privx-sdk-go/api/monitor"
+	"github.com/SSHcom/privx-sdk-go/api/networkaccessmanager"
+	"github.com/SSHcom/privx-sdk-go/api/workflow"
+)
+
+// page size used when walking through all items of a list
+const pageSize = 100
+
+// serviceStatus is the status document reported by PrivX services
+type serviceStatus struct {
+	Status        string `json:"status"`
+	StatusMessage string `json:"status_message"`
+	Version       string `json:"version"`
+}
+
+type componentStatus struct {
+	serviceStatus
+	Hostname string `json:"hostname"`
+	Name     string `json:"name"`
+}
+
+// up tells whether the reported status of PrivX service is healthy
+func (status serviceStatus) up() bool {
+	switch strings.ToLower(status.Status) {
+	case "ok", "up", "running", "healthy", "active":
+		return true
+	}
+	return false
+}
+
+// requestPending tells whether the workflow request waits for a decision
+func requestPending(request workflow.Request) bool {
+	return strings.EqualFold(request.RequestStatus, "PENDING")
+}
+
+func (component componentStatus) name() string {
+	if component.Hostname != "" {
+		return component.Hostname
+	}
+	return component.Name
+}
+
+// licenseExpiresIn returns time left until the license expires
+func licenseExpiresIn(license *licensemanager.License) (time.Duration, bool) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if expiry, err := time.Parse(layout, license.Expiry); err == nil {
+			return time.Until(expiry), true
+		}
+	}
+	return 0, false
+}
+
+func componentsStatus() ([]componentStatus, error) {
+	status, err := monitor.New(curl()).ComponentsStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	components := []componentStatus{}
+	if status != nil {
+		err = json.Unmarshal(*status, &components)
+	}
+
+	return components, err
+}
+
+func instanceStatus() (serviceStatus, error) {
+	instance := serviceStatus{}
+
+	status, err := monitor.New(curl()).InstanceStatus()
+	if err != nil {
+		return instance, err
+	}
+
+	if status != nil {
+		err = json.Unmarshal(*status, &instance)
+	}
+
+	return instance, err
+}
+
+func licenseUsage() (*licensemanager.License, error) {
+	return licensemanager.New(curl()).License()
+}
+
+func namStatus() (serviceStatus, error) {
+	status, err := networkaccessmanager.New(curl()).NetworkAccessManagerStatus()
+	if err != nil {
+		return serviceStatus{}, err
+	}
+
+	return serviceStatus{Status: status.Status, StatusMessage: status.StatusMessage, Version: status.Version}, nil
+}
+
+func uebaServiceStatus() (serviceStatus, error) {
+	status, err := connectionmanager.New(curl()).UebaStatus()
+	if err != nil {
+		return serviceStatus{}, err
+	}
+
+	return serviceStatus{Status: status.Status, StatusMessage: status.StatusMessage, Version: status.Version}, nil
+}
+
+// searchConnections walks through all connections matching the search object
+func searchConnections(search connectionmanager.ConnectionSearch) ([]connectionmanager.Connection, error) {
+	api := connectionmanager.New(curl())
+	connections := []connectionmanager.Connection{}
+
+	for offset := 0; ; offset += pageSize {
+		page, err := api.SearchConnections(offset, pageSize, "DESC", "connected", false, search)
+		if err != nil {
+			return nil, err
+		}
+		connections = append(connections, page...)
+
+		if len(page) < pageSize {
+			return connections, nil
+		}
+	}
+}
+
+// lastConnections finds the latest connection time per key of connection
+func lastConnections(key func(connectionmanager.Connection) string) (map[string]time.Time, error) {
+	conns, err := searchConnections(connectionmanager.ConnectionSearch{})
+	if err != nil {
+		return nil, err
+	}
+
+	last := map[string]time.Time{}
+	for _, conn := range conns {
+		connected, err := time.Parse(time.RFC3339, conn.Connected)
+		if err != nil {
+			continue
+		}
+		if connected.After(last[key(conn)]) {
+			last[key(conn)] = connected
+		}
+	}
+
+	return last, nil
+}
+
+// activeConnections counts connections in progress per protocol
+func activeConnections() (map[string]int, error) {
+	conns, err := searchConnections(connectionmanager.ConnectionSearch{Status: []string{"CONNECTED"}})
+	if err != nil {
+		return nil, err
+	}
+
+	protocols := map[string]int{}
+	for _, conn := range conns {
+		protocols[strings.ToLower(conn.Type)]++
+	}
+
+	return protocols, nil
+}
+
+// pendingApprovals counts workflow requests waiting for a decision
+func pendingApprovals() (int, error) {
+	api := workflow.New(curl())
+	pending := 0
+
+	for offset := 0; ; offset += pageSize {
+		requests, err := api.Requests(offset, pageSize, "approvals")
+		if err != nil {
+			return 0, err
+		}
+
+		for _, request := range requests {
+			if requestPending(request) {
+				pending++
+			}
+		}
+
+		if len(requests) < pageSize {
+			return pending, nil
+		}
+	}
+}
@@ -0,0 +1,148 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// table is a tabular report, rendered as aligned text, CSV or HTML
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+// csvTable is a CSV file read by its header row, cells are trimmed and
+// keyed by lower case column name
+type csvTable struct {
+	columns map[string]bool
+	records []csvRecord
+}
+
+type csvRecord struct {
+	row   int
+	cells map[string]string
+}
+
+func newTable(header ...string) *table {
+	return &table{header: header}
+}
+
+func (t *table) add(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+func (t *table) write(w io.Writer, format string) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(t.header, "\t"))
+		for _, row := range t.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(t.header)
+		for _, row := range t.rows {
+			cw.Write(csvCells(row))
+		}
+		cw.Flush()
+		return cw.Error()
+	case "html":
+		fmt.Fprintln(w, "<table>")
+		fmt.Fprintln(w, htmlRow("th", t.header))
+		for _, row := range t.rows {
+			fmt.Fprintln(w, htmlRow("td", row))
+		}
+		fmt.Fprintln(w, "</table>")
+		return nil
+	default:
+		return fmt.Errorf("output format does not exist: %s", format)
+	}
+}
+
+// report prints items as JSON or renders them as table with the given format
+func report(format string, items interface{}, render func() *table) error {
+	if format == "json" {
+		return stdout(items)
+	}
+	return render().write(os.Stdout, format)
+}
+
+func htmlRow(tag string, cells []string) string {
+	row := "<tr>"
+	for _, cell := range cells {
+		row += fmt.Sprintf("<%s>%s</%s>", tag, html.EscapeString(cell), tag)
+	}
+	return row + "</tr>"
+}
+
+// csvCells escapes cells which spreadsheets would evaluate as formulas
+func csvCells(cells []string) []string {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			cell = "'" + cell
+		}
+		escaped[i] = cell
+	}
+	return escaped
+}
+
+// readCSVTable reads CSV file having header row, rows are numbered by their
+// position in the file
+func readCSVTable(file io.Reader) (*csvTable, error) {
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &csvTable{columns: map[string]bool{}, records: []csvRecord{}}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+		t.columns[header[i]] = true
+	}
+
+	for row := 2; ; row++ {
+		line, err := reader.Read()
+		if err == io.EOF {
+			return t, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		record := csvRecord{row: row, cells: map[string]string{}}
+		for i, column := range header {
+			if i < len(line) {
+				record.cells[column] = strings.TrimSpace(line[i])
+			}
+		}
+		t.records = append(t.records, record)
+	}
+}
+
+// require fails unless the file has all the columns
+func (t *csvTable) require(columns ...string) error {
+	for _, column := range columns {
+		if !t.columns[column] {
+			return fmt.Errorf("file has no %s column", column)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCSVCells(t *testing.T) {
+	cells := []string{"", "alice", "=HYPERLINK(\"x\")", "+1", "-cmd", "@SUM(A1)", "\tx", "a=b", "'quoted"}
+	expected := []string{"", "alice", "'=HYPERLINK(\"x\")", "'+1", "'-cmd", "'@SUM(A1)", "'\tx", "a=b", "'quoted"}
+
+	if escaped := csvCells(cells); !reflect.DeepEqual(escaped, expected) {
+		t.Errorf("csvCells(%q) = %q, expected %q", cells, escaped, expected)
+	}
+}
+
+func TestReadCSVTable(t *testing.T) {
+	input := " Name ,ROLE\n alice , \"admins, ops\"\nbob\n"
+
+	table, err := readCSVTable(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := table.require("name", "role"); err != nil {
+		t.Error(err)
+	}
+	if err := table.require("name", "email"); err == nil {
+		t.Error("missing email column is not reported")
+	}
+
+	expected := []csvRecord{
+		{row: 2, cells: map[string]string{"name": "alice", "role": "admins, ops"}},
+		{row: 3, cells: map[string]string{"name": "bob"}},
+	}
+	if !reflect.DeepEqual(table.records, expected) {
+		t.Errorf("records %+v, expected %+v", table.records, expected)
+	}
+}
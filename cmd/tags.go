@@ -8,6 +8,7 @@ package cmd
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/SSHcom/privx-sdk-go/api/hoststore"
@@ -56,6 +57,8 @@ func tagListCmd() *cobra.Command {
 	flags.StringVar(&options.tagType, "type", "", "choose the tag type, user or host")
 	cmd.MarkFlagRequired("type")
 
+	cmd.AddCommand(tagUsageCmd())
+
 	return cmd
 }
 
@@ -95,3 +98,87 @@ func hostTags(options tagOptions) error {
 
 	return stdout(tags)
 }
+
+type tagUsage struct {
+	Tag   string `json:"tag"`
+	Hosts int    `json:"hosts"`
+	Users int    `json:"users"`
+}
+
+//
+//
+func tagUsageCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Count hosts and local users per tag",
+		Long:  `Report how many hosts and local users carry each tag`,
+		Example: `
+	privx-cli tags usage [access flags]
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return tagUsageReport()
+		},
+	}
+
+	return cmd
+}
+
+func tagUsageReport() error {
+	usage := map[string]*tagUsage{}
+	count := func(tag string) *tagUsage {
+		if usage[tag] == nil {
+			usage[tag] = &tagUsage{Tag: tag}
+		}
+		return usage[tag]
+	}
+
+	hosts, err := allHosts(hoststore.New(curl()))
+	if err != nil {
+		return err
+	}
+
+	for _, host := range hosts {
+		for _, tag := range uniqueStrings(host.Tags) {
+			count(tag).Hosts++
+		}
+	}
+
+	users, err := allLocalUsers()
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		for _, tag := range uniqueStrings(user.Tags) {
+			count(tag).Users++
+		}
+	}
+
+	report := []tagUsage{}
+	for _, tag := range usage {
+		report = append(report, *tag)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Tag < report[j].Tag })
+
+	return stdout(report)
+}
+
+// allLocalUsers walks through all pages of local users
+func allLocalUsers() ([]userstore.LocalUser, error) {
+	api := userstore.New(curl())
+	users := []userstore.LocalUser{}
+
+	for offset := 0; ; offset += pageSize {
+		page, err := api.LocalUsers(offset, pageSize, "", "")
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, page...)
+
+		if len(page) < pageSize {
+			return users, nil
+		}
+	}
+}
@@ -0,0 +1,89 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/spf13/cobra"
+)
+
+type userFindOptions struct {
+	username    string
+	email       string
+	source      string
+	role        string
+	mfaDisabled bool
+}
+
+//
+//
+func userFindCmd() *cobra.Command {
+	options := userFindOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "find",
+		Short: "Find users by attributes",
+		Long: `Find users by attributes. Username and email match case insensitively any part
+of the value, role is given by ID or name. All given conditions must match.`,
+		Example: `
+	privx-cli users find [access flags] --username alice
+	privx-cli users find [access flags] --email @example.com --source <SOURCE-ID>
+	privx-cli users find [access flags] --role <ROLE-ID> --mfa-disabled
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return userFind(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.username, "username", "", "username or part of it")
+	flags.StringVar(&options.email, "email", "", "email or part of it")
+	flags.StringVar(&options.source, "source", "", "user source ID")
+	flags.StringVar(&options.role, "role", "", "role ID or name")
+	flags.BoolVar(&options.mfaDisabled, "mfa-disabled", false, "only users without multi-factor authentication")
+
+	return cmd
+}
+
+func userFind(options userFindOptions) error {
+	users, err := searchAllUsers(rolestore.UserSearchObject{Source: options.source})
+	if err != nil {
+		return err
+	}
+
+	found := []rolestore.User{}
+	for _, user := range users {
+		if options.matches(user) {
+			found = append(found, user)
+		}
+	}
+
+	return stdout(found)
+}
+
+func (options userFindOptions) matches(user rolestore.User) bool {
+	contains := func(value, part string) bool {
+		return strings.Contains(strings.ToLower(value), strings.ToLower(part))
+	}
+
+	switch {
+	case options.username != "" && !contains(user.Principal, options.username):
+		return false
+	case options.email != "" && !contains(user.Email, options.email):
+		return false
+	case options.source != "" && user.SourceID != options.source:
+		return false
+	case options.role != "" && !userHasRole(user, options.role):
+		return false
+	case options.mfaDisabled && user.MFA.Enabled:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,281 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/spf13/cobra"
+)
+
+type userMFAReportOptions struct {
+	privileged []string
+	strict     bool
+}
+
+type userMFAEnforceOptions struct {
+	role   string
+	dryRun bool
+}
+
+// mfaUser is a user without multi-factor authentication
+type mfaUser struct {
+	ID        string   `json:"id"`
+	Principal string   `json:"principal"`
+	SourceID  string   `json:"source_id"`
+	Source    string   `json:"source"`
+	Roles     []string `json:"roles"`
+}
+
+// mfaCount is a breakdown of users by source or role
+type mfaCount struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Privileged bool   `json:"privileged,omitempty"`
+	Users      int    `json:"users"`
+	WithoutMFA int    `json:"without_mfa"`
+}
+
+// mfaViolation is a privileged role having members without MFA
+type mfaViolation struct {
+	RoleID string   `json:"role_id"`
+	Role   string   `json:"role"`
+	Users  []string `json:"users"`
+}
+
+type mfaReport struct {
+	Users      int            `json:"users"`
+	WithoutMFA []mfaUser      `json:"without_mfa"`
+	BySource   []mfaCount     `json:"by_source"`
+	ByRole     []mfaCount     `json:"by_role"`
+	Violations []mfaViolation `json:"privileged_violations"`
+}
+
+type mfaChange struct {
+	UserID string `json:"user_id"`
+	User   string `json:"user"`
+	Status string `json:"status"`
+}
+
+//
+//
+func userMFAReportCmd() *cobra.Command {
+	options := userMFAReportOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Report users without multifactor authentication",
+		Long: `Report users without multifactor authentication, broken down by user source
+and role. Privileged roles having members without multifactor authentication are
+listed as violations. Roles are privileged when given with --privileged, by
+default every role with admin in its name is privileged.`,
+		Example: `
+	privx-cli users mfa report [access flags]
+	privx-cli users mfa report [access flags] --privileged <ROLE-ID>,<ROLE-NAME> --strict
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return userMFAReport(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringSliceVar(&options.privileged, "privileged", []string{}, "privileged role IDs or names")
+	flags.BoolVar(&options.strict, "strict", false, "fail if privileged roles have members without MFA")
+
+	return cmd
+}
+
+//
+//
+func userMFAEnforceCmd() *cobra.Command {
+	options := userMFAEnforceOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "enforce",
+		Short: "Enable multifactor authentication for members of the role",
+		Long:  `Enable multifactor authentication for every member of the role not having it yet`,
+		Example: `
+	privx-cli users mfa enforce [access flags] --role <ROLE-ID> --dry-run
+	privx-cli users mfa enforce [access flags] --role <ROLE-NAME>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return userMFAEnforce(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.role, "role", "", "role ID or name")
+	flags.BoolVar(&options.dryRun, "dry-run", false, "list the users without enabling MFA")
+	cmd.MarkFlagRequired("role")
+
+	return cmd
+}
+
+func userMFAReport(options userMFAReportOptions) error {
+	api := rolestore.New(curl())
+
+	users, err := searchAllUsers(rolestore.UserSearchObject{})
+	if err != nil {
+		return err
+	}
+
+	roles, err := api.Roles()
+	if err != nil {
+		return err
+	}
+
+	sources := map[string]string{}
+	if found, err := api.Sources(); err == nil {
+		for _, source := range found {
+			sources[source.ID] = source.Name
+		}
+	}
+
+	privileged := map[string]bool{}
+	for _, role := range roles {
+		if len(options.privileged) == 0 {
+			privileged[role.ID] = strings.Contains(strings.ToLower(role.Name), "admin")
+			continue
+		}
+		for _, name := range options.privileged {
+			if role.ID == name || strings.EqualFold(role.Name, name) {
+				privileged[role.ID] = true
+			}
+		}
+	}
+
+	bySource := map[string]*mfaCount{}
+	byRole := map[string]*mfaCount{}
+	violations := map[string]*mfaViolation{}
+	report := mfaReport{Users: len(users), WithoutMFA: []mfaUser{}, Violations: []mfaViolation{}}
+
+	for _, user := range users {
+		source, ok := bySource[user.SourceID]
+		if !ok {
+			source = &mfaCount{ID: user.SourceID, Name: sources[user.SourceID]}
+			bySource[user.SourceID] = source
+		}
+		source.Users++
+
+		for _, role := range user.Roles {
+			count, ok := byRole[role.ID]
+			if !ok {
+				count = &mfaCount{ID: role.ID, Name: role.Name, Privileged: privileged[role.ID]}
+				byRole[role.ID] = count
+			}
+			count.Users++
+		}
+
+		if user.MFA.Enabled {
+			continue
+		}
+
+		missing := mfaUser{
+			ID:        user.ID,
+			Principal: user.Principal,
+			SourceID:  user.SourceID,
+			Source:    sources[user.SourceID],
+			Roles:     []string{},
+		}
+		source.WithoutMFA++
+
+		for _, role := range user.Roles {
+			byRole[role.ID].WithoutMFA++
+			missing.Roles = append(missing.Roles, role.Name)
+
+			if privileged[role.ID] {
+				if _, ok := violations[role.ID]; !ok {
+					violations[role.ID] = &mfaViolation{RoleID: role.ID, Role: role.Name}
+				}
+				violations[role.ID].Users = append(violations[role.ID].Users, user.Principal)
+			}
+		}
+
+		report.WithoutMFA = append(report.WithoutMFA, missing)
+	}
+
+	report.BySource = sortedMFACounts(bySource)
+	report.ByRole = sortedMFACounts(byRole)
+	for _, violation := range violations {
+		sort.Strings(violation.Users)
+		report.Violations = append(report.Violations, *violation)
+	}
+	sort.Slice(report.Violations, func(i, j int) bool { return report.Violations[i].Role < report.Violations[j].Role })
+	sort.Slice(report.WithoutMFA, func(i, j int) bool { return report.WithoutMFA[i].Principal < report.WithoutMFA[j].Principal })
+
+	if err := stdout(report); err != nil {
+		return err
+	}
+
+	if options.strict && len(report.Violations) > 0 {
+		return fmt.Errorf("%d privileged roles have members without MFA", len(report.Violations))
+	}
+
+	return nil
+}
+
+func sortedMFACounts(counts map[string]*mfaCount) []mfaCount {
+	sorted := []mfaCount{}
+	for _, count := range counts {
+		sorted = append(sorted, *count)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
+
+func userMFAEnforce(options userMFAEnforceOptions) error {
+	api := rolestore.New(curl())
+
+	lookup, err := roleIDs(api)
+	if err != nil {
+		return err
+	}
+
+	roleID, ok := lookup(options.role)
+	if !ok {
+		return fmt.Errorf("role does not exist: %s", options.role)
+	}
+
+	members, err := api.GetRoleMembers(roleID)
+	if err != nil {
+		return err
+	}
+
+	ids := []string{}
+	changes := []mfaChange{}
+	for _, user := range members {
+		if user.MFA.Enabled {
+			continue
+		}
+
+		ids = append(ids, user.ID)
+		changes = append(changes, mfaChange{UserID: user.ID, User: user.Principal, Status: "planned"})
+	}
+
+	if options.dryRun || len(ids) == 0 {
+		return stdout(changes)
+	}
+
+	status, failure := "enabled", api.EnableMFA(ids)
+	if failure != nil {
+		status = "error"
+	}
+	for i := range changes {
+		changes[i].Status = status
+	}
+
+	if err := stdout(changes); err != nil {
+		return err
+	}
+
+	return failure
+}
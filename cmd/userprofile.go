@@ -0,0 +1,144 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"sync"
+
+	authApi "github.com/SSHcom/privx-sdk-go/api/auth"
+	"github.com/SSHcom/privx-sdk-go/api/connectionmanager"
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/SSHcom/privx-sdk-go/api/workflow"
+	"github.com/spf13/cobra"
+)
+
+type userProfileOptions struct {
+	userID string
+}
+
+// userProfile aggregates user related records, sections which could not
+// be fetched are reported in errors
+type userProfile struct {
+	User            interface{}       `json:"user"`
+	Settings        interface{}       `json:"settings"`
+	Roles           interface{}       `json:"roles"`
+	AuthorizedKeys  interface{}       `json:"authorized_keys"`
+	Sessions        interface{}       `json:"sessions"`
+	Connections     interface{}       `json:"connections"`
+	Devices         interface{}       `json:"devices"`
+	PendingRequests interface{}       `json:"pending_requests"`
+	Errors          map[string]string `json:"errors,omitempty"`
+}
+
+//
+//
+func userProfileCmd() *cobra.Command {
+	options := userProfileOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Show everything related to the user",
+		Long: `Show user record, settings, roles, authorized keys, active sessions, active
+connections, paired mobile devices and pending workflow requests of the user
+as one document.`,
+		Example: `
+	privx-cli users profile [access flags] --id <USER-ID>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return userProfileShow(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.userID, "id", "", "user ID")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+func userProfileShow(options userProfileOptions) error {
+	id := options.userID
+	roles := rolestore.New(curl())
+	auth := authApi.New(curl())
+
+	profile := userProfile{Errors: map[string]string{}}
+	sections := []struct {
+		name  string
+		value *interface{}
+		fetch func() (interface{}, error)
+	}{
+		{"user", &profile.User, func() (interface{}, error) { return roles.User(id) }},
+		{"settings", &profile.Settings, func() (interface{}, error) { return roles.UserSettings(id) }},
+		{"roles", &profile.Roles, func() (interface{}, error) { return roles.UserRoles(id) }},
+		{"authorized_keys", &profile.AuthorizedKeys, func() (interface{}, error) { return roles.AuthorizedKeys(id) }},
+		{"sessions", &profile.Sessions, func() (interface{}, error) { return userSessions(auth, id) }},
+		{"connections", &profile.Connections, func() (interface{}, error) {
+			return searchConnections(connectionmanager.ConnectionSearch{Status: []string{"CONNECTED"}, UserID: []string{id}})
+		}},
+		{"devices", &profile.Devices, func() (interface{}, error) { return auth.GetUserPairedDevices(id) }},
+		{"pending_requests", &profile.PendingRequests, func() (interface{}, error) { return pendingUserRequests(id) }},
+	}
+
+	var lock sync.Mutex
+	parallel(len(sections), len(sections), func(i int) {
+		value, err := sections[i].fetch()
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		if err != nil {
+			profile.Errors[sections[i].name] = err.Error()
+			return
+		}
+		*sections[i].value = value
+	})
+
+	return stdout(profile)
+}
+
+// userSessions walks through all pages of sessions of the user
+func userSessions(auth *authApi.Client, userID string) ([]authApi.Session, error) {
+	sessions := []authApi.Session{}
+
+	for offset := 0; ; offset += pageSize {
+		page, err := auth.UserSessions(offset, pageSize, "", "", userID)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, page...)
+
+		if len(page) < pageSize {
+			return sessions, nil
+		}
+	}
+}
+
+// pendingUserRequests lists pending workflow requests made by or for the user,
+// requests are searched by status and user
+func pendingUserRequests(userID string) ([]workflow.Request, error) {
+	api := workflow.New(curl())
+	pending := []workflow.Request{}
+	search := workflow.Search{Status: []string{"PENDING"}, UserID: userID}
+
+	for offset := 0; ; offset += pageSize {
+		requests, err := api.SearchRequests(offset, pageSize, "", "", "", &search)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, request := range requests {
+			if requestPending(request) && (request.Requester.ID == userID || request.TargetUser.ID == userID) {
+				pending = append(pending, request)
+			}
+		}
+
+		if len(requests) < pageSize {
+			return pending, nil
+		}
+	}
+}
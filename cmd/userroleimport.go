@@ -0,0 +1,324 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/spf13/cobra"
+)
+
+type userRoleImportOptions struct {
+	workers int
+	apply   bool
+	format  string
+}
+
+type userRoleChange struct {
+	Row    int    `json:"row"`
+	User   string `json:"user"`
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+	RoleID string `json:"role_id"`
+	Action string `json:"action"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+//
+//
+func userRoleImportCmd() *cobra.Command {
+	options := userRoleImportOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Grant and revoke roles of users from CSV file",
+		Long: `Grant and revoke roles of users from CSV file with columns username or user_id,
+role and action (grant or revoke). Role is given by ID or name. Rows which would
+not change anything are reported as noop, repeated rows as duplicate. The plan is
+printed unless --apply is given. Rows of one user are applied in order, --workers
+users in parallel.`,
+		Example: `
+	privx-cli users roles import [access flags] roles.csv
+	privx-cli users roles import [access flags] --apply --workers 8 roles.csv
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return userRoleImport(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.IntVar(&options.workers, "workers", 4, "number of concurrent requests")
+	flags.BoolVar(&options.apply, "apply", false, "grant and revoke the roles, by default only plan is shown")
+	flags.StringVar(&options.format, "format", "json", "output format, table, csv or json")
+
+	return cmd
+}
+
+func userRoleImport(options userRoleImportOptions, args []string) error {
+	changes, err := readUserRoleChanges(args[0])
+	if err != nil {
+		return err
+	}
+
+	api := rolestore.New(curl())
+
+	if err := resolveRoleNames(api, changes); err != nil {
+		return err
+	}
+	resolveUserNames(changes, options.workers)
+	planUserRoleChanges(api, changes, options.workers)
+
+	if options.apply {
+		// grant and revoke update the role list of the user, so changes of
+		// one user are applied in order and only different users run in parallel
+		users := groupBy(len(changes), func(i int) string { return changes[i].UserID })
+
+		parallel(len(users), options.workers, func(n int) {
+			for _, i := range users[n] {
+				change := &changes[i]
+
+				var err error
+				switch change.Status {
+				case "grant":
+					err = api.GrantUserRole(change.UserID, change.RoleID)
+					change.Status = "granted"
+				case "revoke":
+					err = api.RevokeUserRole(change.UserID, change.RoleID)
+					change.Status = "revoked"
+				default:
+					continue
+				}
+
+				if err != nil {
+					change.Status, change.Error = "error", err.Error()
+				}
+			}
+		})
+	}
+
+	return report(options.format, changes, func() *table {
+		t := newTable("ROW", "USER", "ROLE", "ACTION", "STATUS", "ERROR")
+		for _, c := range changes {
+			t.add(fmt.Sprint(c.Row), c.User, c.Role, c.Action, c.Status, c.Error)
+		}
+		return t
+	})
+}
+
+func readUserRoleChanges(name string) ([]userRoleChange, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	input, err := readCSVTable(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if !input.columns["username"] && !input.columns["user_id"] {
+		return nil, fmt.Errorf("file has neither username nor user_id column")
+	}
+	if err := input.require("role", "action"); err != nil {
+		return nil, err
+	}
+
+	changes := []userRoleChange{}
+	for _, record := range input.records {
+		change := userRoleChange{
+			Row:    record.row,
+			User:   firstOf(record.cells["username"], record.cells["user_id"]),
+			UserID: record.cells["user_id"],
+			Role:   record.cells["role"],
+			Action: strings.ToLower(record.cells["action"]),
+		}
+
+		switch {
+		case change.User == "" || change.Role == "":
+			change.Status, change.Error = "error", "user and role are required"
+		case change.Action != "grant" && change.Action != "revoke":
+			change.Status, change.Error = "error", "action must be grant or revoke"
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// roleIDs returns lookup of role IDs by role ID or name
+func roleIDs(api *rolestore.RoleStore) (func(role string) (string, bool), error) {
+	roles, err := api.Roles()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := map[string]string{}
+	for _, role := range roles {
+		ids[role.ID] = role.ID
+		ids[strings.ToLower(role.Name)] = role.ID
+	}
+
+	return func(role string) (string, bool) {
+		id, ok := ids[role]
+		if !ok {
+			id, ok = ids[strings.ToLower(role)]
+		}
+		return id, ok
+	}, nil
+}
+
+// resolveRoleNames sets role IDs of changes, roles are given by ID or name
+func resolveRoleNames(api *rolestore.RoleStore, changes []userRoleChange) error {
+	lookup, err := roleIDs(api)
+	if err != nil {
+		return err
+	}
+
+	for i := range changes {
+		change := &changes[i]
+		if change.Status != "" {
+			continue
+		}
+
+		id, ok := lookup(change.Role)
+		if !ok {
+			change.Status, change.Error = "error", "role does not exist"
+		} else {
+			change.RoleID = id
+		}
+	}
+
+	return nil
+}
+
+// resolveUserNames sets user IDs of changes given by username
+func resolveUserNames(changes []userRoleChange, workers int) {
+	names := []string{}
+	for _, change := range changes {
+		if change.Status == "" && change.UserID == "" {
+			names = append(names, change.User)
+		}
+	}
+	names = uniqueStrings(names)
+
+	ids := make([]string, len(names))
+	failures := make([]string, len(names))
+
+	parallel(len(names), workers, func(i int) {
+		users, err := searchAllUsers(rolestore.UserSearchObject{Keywords: names[i]})
+		if err != nil {
+			failures[i] = err.Error()
+			return
+		}
+
+		for _, user := range users {
+			if strings.EqualFold(user.Principal, names[i]) {
+				if ids[i] != "" {
+					failures[i] = "username is ambiguous"
+					return
+				}
+				ids[i] = user.ID
+			}
+		}
+
+		if ids[i] == "" {
+			failures[i] = "user does not exist"
+		}
+	})
+
+	resolved := map[string]int{}
+	for i, name := range names {
+		resolved[name] = i
+	}
+
+	for i := range changes {
+		change := &changes[i]
+		if change.Status != "" || change.UserID != "" {
+			continue
+		}
+
+		n := resolved[change.User]
+		if failures[n] != "" {
+			change.Status, change.Error = "error", failures[n]
+		} else {
+			change.UserID = ids[n]
+		}
+	}
+}
+
+// planUserRoleChanges compares changes to current explicit roles of users
+func planUserRoleChanges(api *rolestore.RoleStore, changes []userRoleChange, workers int) {
+	seen := map[string]int{}
+	users := []string{}
+
+	for i := range changes {
+		change := &changes[i]
+		if change.Status != "" {
+			continue
+		}
+
+		key := change.UserID + "/" + change.RoleID
+		if first, ok := seen[key]; ok {
+			if changes[first].Action != change.Action {
+				change.Status, change.Error = "error", fmt.Sprintf("conflicts with row %d", changes[first].Row)
+			} else {
+				change.Status, change.Error = "duplicate", fmt.Sprintf("duplicate of row %d", changes[first].Row)
+			}
+			continue
+		}
+
+		seen[key] = i
+		users = append(users, change.UserID)
+	}
+	users = uniqueStrings(users)
+
+	var lock sync.Mutex
+	current := map[string]map[string]bool{}
+	failures := map[string]string{}
+
+	parallel(len(users), workers, func(i int) {
+		roles, err := explicitRoles(api, users[i])
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		if err != nil {
+			failures[users[i]] = err.Error()
+			return
+		}
+
+		current[users[i]] = map[string]bool{}
+		for _, role := range roles {
+			current[users[i]][role.ID] = true
+		}
+	})
+
+	for i := range changes {
+		change := &changes[i]
+		if change.Status != "" {
+			continue
+		}
+
+		switch has := current[change.UserID][change.RoleID]; {
+		case failures[change.UserID] != "":
+			change.Status, change.Error = "error", failures[change.UserID]
+		case change.Action == "grant" && has, change.Action == "revoke" && !has:
+			change.Status = "noop"
+		default:
+			change.Status = change.Action
+		}
+	}
+}
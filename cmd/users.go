@@ -8,9 +8,11 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/SSHcom/privx-sdk-go/api/rolestore"
 	"github.com/spf13/cobra"
@@ -34,6 +36,8 @@ type userOptions struct {
 	userRoleGrant  []string
 	userRoleRevoke []string
 	userIDs        []string
+	grantFor       time.Duration
+	jitState       string
 	search         UserSearchOptions
 }
 
@@ -75,6 +79,9 @@ func userListCmd() *cobra.Command {
 	cmd.AddCommand(usersRolesCmd())
 	cmd.AddCommand(userMFACmd())
 	cmd.AddCommand(externalUserSearchCmd())
+	cmd.AddCommand(userOffboardCmd())
+	cmd.AddCommand(userFindCmd())
+	cmd.AddCommand(userProfileCmd())
 
 	return cmd
 }
@@ -258,6 +265,7 @@ func usersRolesCmd() *cobra.Command {
 	privx-cli users roles [access flags] --id <USER-ID>
 	privx-cli users roles [access flags] --id <USER-ID> --grant <ROLE-ID>
 	privx-cli users roles [access flags] --id <USER-ID> --revoke <ROLE-ID>
+	privx-cli users roles [access flags] --id <USER-ID> --grant <ROLE-ID> --for 4h
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -269,17 +277,50 @@ func usersRolesCmd() *cobra.Command {
 	flags.StringVar(&options.userID, "id", "", "user ID")
 	flags.StringArrayVar(&options.userRoleGrant, "grant", []string{}, "grant role to user, requires role unique id.")
 	flags.StringArrayVar(&options.userRoleRevoke, "revoke", []string{}, "revoke role from user, requires role unique id.")
+	flags.DurationVar(&options.grantFor, "for", 0, "revoke granted roles after given time, enforced by jit daemon")
+	flags.StringVar(&options.jitState, "state", jitDefaultState(), "state file of time-boxed grants")
 	cmd.MarkFlagRequired("id")
 
+	cmd.AddCommand(userRoleImportCmd())
+
 	return cmd
 }
 
 func userRoles(options userOptions) error {
+	if options.grantFor < 0 || (options.grantFor > 0 && len(options.userRoleGrant) == 0) {
+		return errors.New("--for requires positive duration and --grant")
+	}
+
 	api := rolestore.New(curl())
 
-	for _, role := range options.userRoleGrant {
+	// expiry is recorded before granting, so that no time-boxed grant is
+	// left permanent
+	if options.grantFor > 0 {
+		permanent, err := permanentGrants(api, options.jitState, options.userID, options.userRoleGrant)
+		if err != nil {
+			return err
+		}
+		if len(permanent) > 0 {
+			return fmt.Errorf("roles are already granted without expiry: %s", strings.Join(permanent, ", "))
+		}
+
+		err = recordJITGrants(options.jitState, options.userID, options.userRoleGrant, options.grantFor)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, role := range options.userRoleGrant {
 		err := api.GrantUserRole(options.userID, role)
 		if err != nil {
+			if options.grantFor > 0 {
+				cleanup := updateJITState(options.jitState, func(grants []jitGrant) []jitGrant {
+					return forgetJITGrants(grants, options.userID, options.userRoleGrant[i:])
+				})
+				if cleanup != nil {
+					return fmt.Errorf("%w, expiry of roles not granted is left in %s: %v", err, options.jitState, cleanup)
+				}
+			}
 			return err
 		}
 	}
@@ -291,6 +332,21 @@ func userRoles(options userOptions) error {
 		}
 	}
 
+	// permanent grants and revokes cancel pending expiry of the roles
+	permanent := options.userRoleRevoke
+	if options.grantFor == 0 {
+		permanent = append(permanent, options.userRoleGrant...)
+	}
+
+	if _, err := os.Stat(options.jitState); len(permanent) > 0 && err == nil {
+		err := updateJITState(options.jitState, func(grants []jitGrant) []jitGrant {
+			return forgetJITGrants(grants, options.userID, permanent)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	roles, err := api.UserRoles(options.userID)
 	if err != nil {
 		return err
@@ -323,6 +379,9 @@ func userMFACmd() *cobra.Command {
 	flags.BoolVarP(&options.reset, "reset", "r", false, "reset multifactor authentication")
 	cmd.MarkFlagRequired("id")
 
+	cmd.AddCommand(userMFAReportCmd())
+	cmd.AddCommand(userMFAEnforceCmd())
+
 	return cmd
 }
 
@@ -410,3 +469,31 @@ func externalUserSearch(options userOptions) error {
 
 	return stdout(users)
 }
+
+// userHasRole tells whether user has role given by ID or name
+func userHasRole(user rolestore.User, role string) bool {
+	for _, r := range user.Roles {
+		if r.ID == role || strings.EqualFold(r.Name, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// searchAllUsers walks through all pages of users matching the search object
+func searchAllUsers(search rolestore.UserSearchObject) ([]rolestore.User, error) {
+	api := rolestore.New(curl())
+	users := []rolestore.User{}
+
+	for offset := 0; ; offset += pageSize {
+		page, err := api.SearchUsers(offset, pageSize, "", "", search)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, page...)
+
+		if len(page) < pageSize {
+			return users, nil
+		}
+	}
+}
@@ -8,8 +8,16 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/SSHcom/privx-sdk-go/restapi"
 )
 
 func decodeJSON(name string, object interface{}) error {
@@ -31,3 +39,138 @@ func decodeJSON(name string, object interface{}) error {
 
 	return nil
 }
+
+// lookupPath resolves a jq style path (e.g. .items[0].id) from decoded JSON
+func lookupPath(object interface{}, path string) (interface{}, error) {
+	keys := strings.Split(strings.ReplaceAll(path, "[", ".["), ".")
+
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+
+		if strings.HasPrefix(key, "[") && strings.HasSuffix(key, "]") {
+			index, err := strconv.Atoi(key[1 : len(key)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid index %s in path %s", key, path)
+			}
+
+			seq, ok := object.([]interface{})
+			if !ok || index < 0 || index >= len(seq) {
+				return nil, fmt.Errorf("index %s not found in path %s", key, path)
+			}
+			object = seq[index]
+			continue
+		}
+
+		bag, ok := object.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("field %s not found in path %s", key, path)
+		}
+
+		object, ok = bag[key]
+		if !ok {
+			return nil, fmt.Errorf("field %s not found in path %s", key, path)
+		}
+	}
+
+	return object, nil
+}
+
+// reshape converts object into another type through its JSON encoding.
+// It is meant for generic attribute maps, typed SDK fields are read directly.
+func reshape(in, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, out)
+}
+
+// isNotFound tells if the API responded with HTTP status not found
+func isNotFound(err error) bool {
+	var response *restapi.ErrorResponse
+	return errors.As(err, &response) && response.StatusCode == http.StatusNotFound
+}
+
+// parallel calls fn for indexes 0..count-1 using given number of workers
+func parallel(count, workers int, fn func(int)) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < count; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// groupBy returns indexes 0..count-1 grouped by key, groups and indexes
+// within a group are in order of appearance
+func groupBy(count int, key func(int) string) [][]int {
+	groups := [][]int{}
+	seen := map[string]int{}
+
+	for i := 0; i < count; i++ {
+		n, ok := seen[key(i)]
+		if !ok {
+			n = len(groups)
+			seen[key(i)] = n
+			groups = append(groups, []int{})
+		}
+		groups[n] = append(groups[n], i)
+	}
+
+	return groups
+}
+
+// writeSecretFile writes data to the file readable only by the owner,
+// permissions of existing file are restricted as well
+func writeSecretFile(name string, data []byte) error {
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := file.Chmod(0600); err != nil {
+		file.Close()
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// keyValues parses key=value pairs, what names the pairs in errors
+func keyValues(pairs []string, what string) (map[string]string, error) {
+	values := map[string]string{}
+
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s must be key=value: %s", what, pair)
+		}
+		values[key] = value
+	}
+
+	return values, nil
+}
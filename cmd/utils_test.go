@@ -0,0 +1,67 @@
+//
+// Copyright (c) 2024 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestLookupPath(t *testing.T) {
+	var object interface{}
+	document := `{"id": "r1", "items": [{"id": "a"}, {"id": "b", "tags": ["x", "y"]}], "empty": null}`
+	if err := json.Unmarshal([]byte(document), &object); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		path     string
+		expected interface{}
+	}{
+		{".id", "r1"},
+		{"id", "r1"},
+		{".items[1].id", "b"},
+		{".items[1].tags[0]", "x"},
+		{".items[0]", map[string]interface{}{"id": "a"}},
+		{".empty", nil},
+		{".", object},
+		{"", object},
+	} {
+		value, err := lookupPath(object, test.path)
+		if err != nil {
+			t.Errorf("lookupPath(%q): %v", test.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(value, test.expected) {
+			t.Errorf("lookupPath(%q) = %v, expected %v", test.path, value, test.expected)
+		}
+	}
+}
+
+func TestLookupPathErrors(t *testing.T) {
+	var object interface{}
+	if err := json.Unmarshal([]byte(`{"id": "r1", "items": [{"id": "a"}]}`), &object); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{".name", ".items[1]", ".items[-1]", ".items[x]", ".id.name", ".id[0]"} {
+		if value, err := lookupPath(object, path); err == nil {
+			t.Errorf("lookupPath(%q) = %v, expected error", path, value)
+		}
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	keys := []string{"a", "b", "a", "c", "b", "a"}
+	groups := groupBy(len(keys), func(i int) string { return keys[i] })
+
+	expected := [][]int{{0, 2, 5}, {1, 4}, {3}}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("groups %v, expected %v", groups, expected)
+	}
+}
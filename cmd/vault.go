@@ -63,6 +63,9 @@ func secretListCmd() *cobra.Command {
 	flags.IntVar(&options.search.limit, "limit", 50, "number of items to return")
 
 	cmd.AddCommand(secretShowCmd())
+	cmd.AddCommand(secretGetCmd())
+	cmd.AddCommand(secretExecCmd())
+	cmd.AddCommand(secretRenderCmd())
 	cmd.AddCommand(secretCreateCmd())
 	cmd.AddCommand(vaultUpdateCmd())
 	cmd.AddCommand(secretDeleteCmd())
@@ -491,6 +494,7 @@ func userSecretListCmd() *cobra.Command {
 	cmd.MarkFlagRequired("owner-id")
 
 	cmd.AddCommand(userSecretShowCmd())
+	cmd.AddCommand(userSecretGetCmd())
 	cmd.AddCommand(userSecretCreateCmd())
 	cmd.AddCommand(userSecretUpdateCmd())
 	cmd.AddCommand(userSecretDeleteCmd())